@@ -159,6 +159,18 @@ func translateAndroidMkModule(ctx blueprint.SingletonContext, w io.Writer, mod b
 		return nil
 	}
 
+	if amod.Os().Class == Host && amod.Os() != BuildOs {
+		// Make only knows about the build OS, modules for other host OSes such as linux_bionic
+		// are only built and installed by Soong
+		return nil
+	}
+
+	if amod.Target().NativeBridge {
+		// Make only knows about the primary and secondary device arches, native bridge variants
+		// are only built and installed by Soong
+		return nil
+	}
+
 	data, err := provider.AndroidMk()
 	if err != nil {
 		return err
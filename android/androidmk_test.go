@@ -0,0 +1,118 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/blueprint"
+)
+
+func TestAndroidMkSkipsOtherHostOses(t *testing.T) {
+	buildDir, err := ioutil.TempDir("", "soong_androidmk_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(buildDir)
+
+	config := TestConfig(buildDir)
+	config.inMake = true
+	config.Targets = map[OsClass][]Target{
+		Host: {
+			{Os: BuildOs, Arch: Arch{ArchType: X86_64}},
+			{Os: LinuxBionic, Arch: Arch{ArchType: X86_64}},
+			{Os: LinuxMusl, Arch: Arch{ArchType: X86_64}},
+		},
+	}
+
+	ctx := NewContext()
+	ctx.RegisterModuleType("androidmk_host", newAndroidMkHostModule)
+	ctx.MockFileSystem(map[string][]byte{
+		"Blueprints": []byte(`
+			androidmk_host {
+				name: "foo",
+				target: {
+					linux_bionic: {
+						enabled: true,
+					},
+					linux_musl: {
+						enabled: true,
+					},
+				},
+			}
+		`),
+	})
+
+	_, errs := ctx.ParseBlueprintsFiles("Blueprints")
+	fail(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+	fail(t, errs)
+
+	variants := 0
+	ctx.VisitAllModules(func(m blueprint.Module) {
+		if ctx.ModuleName(m) == "foo" {
+			variants++
+		}
+	})
+	if variants != 3 {
+		t.Fatalf("expected a variant for each host OS, got %d variants", variants)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(buildDir, "Android.mk"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mk := string(data)
+
+	if expected := "foo " + BuildOs.String() + "\n"; !strings.Contains(mk, expected) {
+		t.Errorf("expected Android.mk to contain %q, got:\n%s", expected, mk)
+	}
+	for _, hostOs := range []OsType{LinuxBionic, LinuxMusl} {
+		if unexpected := "foo " + hostOs.String() + "\n"; strings.Contains(mk, unexpected) {
+			t.Errorf("expected Android.mk not to contain %q, got:\n%s", unexpected, mk)
+		}
+	}
+}
+
+// androidMkHostModule writes its name and OS to Android.mk for each of its variants
+type androidMkHostModule struct {
+	ModuleBase
+}
+
+func newAndroidMkHostModule() (blueprint.Module, []interface{}) {
+	m := &androidMkHostModule{}
+	return InitAndroidArchModule(m, HostSupported, MultilibFirst)
+}
+
+func (m *androidMkHostModule) DepsMutator(ctx BottomUpMutatorContext) {
+}
+
+func (m *androidMkHostModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+}
+
+func (m *androidMkHostModule) AndroidMk() (AndroidMkData, error) {
+	return AndroidMkData{
+		Custom: func(w io.Writer, name, prefix string) error {
+			_, err := fmt.Fprintln(w, name, m.Os().String())
+			return err
+		},
+	}, nil
+}
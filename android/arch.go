@@ -27,12 +27,13 @@ import (
 var (
 	archTypeList []ArchType
 
-	Arm    = newArch("arm", "lib32")
-	Arm64  = newArch("arm64", "lib64")
-	Mips   = newArch("mips", "lib32")
-	Mips64 = newArch("mips64", "lib64")
-	X86    = newArch("x86", "lib32")
-	X86_64 = newArch("x86_64", "lib64")
+	Arm     = newArch("arm", "lib32")
+	Arm64   = newArch("arm64", "lib64")
+	Mips    = newArch("mips", "lib32")
+	Mips64  = newArch("mips64", "lib64")
+	Riscv64 = newArch("riscv64", "lib64")
+	X86     = newArch("x86", "lib32")
+	X86_64  = newArch("x86_64", "lib64")
 
 	Common = ArchType{
 		Name: "common",
@@ -40,12 +41,13 @@ var (
 )
 
 var archTypeMap = map[string]ArchType{
-	"arm":    Arm,
-	"arm64":  Arm64,
-	"mips":   Mips,
-	"mips64": Mips64,
-	"x86":    X86,
-	"x86_64": X86_64,
+	"arm":     Arm,
+	"arm64":   Arm64,
+	"mips":    Mips,
+	"mips64":  Mips64,
+	"riscv64": Riscv64,
+	"x86":     X86,
+	"x86_64":  X86_64,
 }
 
 /*
@@ -66,6 +68,9 @@ module {
         mips64: {
             // Host or device variants with mips64 architecture
         },
+        riscv64: {
+            // Host or device variants with riscv64 architecture
+        },
         x86: {
             // Host or device variants with x86 architecture
         },
@@ -91,6 +96,12 @@ module {
         linux: {
             // Linux host variants
         },
+        linux_bionic: {
+            // Linux host variants built against bionic
+        },
+        linux_musl: {
+            // Linux host variants built against musl
+        },
         darwin: {
             // Darwin host variants
         },
@@ -144,6 +155,10 @@ type Arch struct {
 	Abi          []string
 	ArchFeatures []string
 	Native       bool
+
+	// Set if ArchVariant or CpuVariant was declared in the arch variants file, see BaseVariants
+	ConfigArchVariant *ConfigArchVariant
+	ConfigCpuVariant  *ConfigArchVariant
 }
 
 func (a Arch) String() string {
@@ -195,15 +210,17 @@ var (
 	Linux       = NewOsType("linux", Host, false)
 	Darwin      = NewOsType("darwin", Host, false)
 	LinuxBionic = NewOsType("linux_bionic", Host, true)
+	LinuxMusl   = NewOsType("linux_musl", Host, true)
 	Windows     = NewOsType("windows", HostCross, true)
 	Android     = NewOsType("android", Device, false)
 
 	osArchTypeMap = map[OsType][]ArchType{
 		Linux:       []ArchType{X86, X86_64},
 		LinuxBionic: []ArchType{X86_64},
+		LinuxMusl:   []ArchType{X86_64},
 		Darwin:      []ArchType{X86, X86_64},
 		Windows:     []ArchType{X86, X86_64},
-		Android:     []ArchType{Arm, Arm64, Mips, Mips64, X86, X86_64},
+		Android:     []ArchType{Arm, Arm64, Mips, Mips64, Riscv64, X86, X86_64},
 	}
 )
 
@@ -260,10 +277,23 @@ var (
 type Target struct {
 	Os   OsType
 	Arch Arch
+
+	// NativeBridge is set for device targets for a guest arch that runs through a native bridge,
+	// for example arm on an x86_64 device.  Only modules with native_bridge_supported: true are
+	// built for these targets.
+	NativeBridge bool
+
+	// NativeBridgeRelativePath is the subdirectory of the lib directories that native bridge
+	// libraries are installed into.
+	NativeBridgeRelativePath string
 }
 
 func (target Target) String() string {
-	return target.Os.String() + "_" + target.Arch.String()
+	s := target.Os.String() + "_" + target.Arch.String()
+	if target.NativeBridge {
+		s += "_native_bridge"
+	}
+	return s
 }
 
 func archMutator(mctx BottomUpMutatorContext) {
@@ -284,6 +314,9 @@ func archMutator(mctx BottomUpMutatorContext) {
 
 	for _, class := range osClasses {
 		targets := mctx.AConfig().Targets[class]
+		if class == Device && !Bool(module.base().commonProperties.Native_bridge_supported) {
+			targets = filterNativeBridgeTargets(targets)
+		}
 		if len(targets) == 0 {
 			continue
 		}
@@ -308,13 +341,17 @@ func archMutator(mctx BottomUpMutatorContext) {
 			// Windows builds always prefer 32-bit
 			prefer32 = true
 		}
-		targets, err := decodeMultilib(multilib, targets, prefer32)
-		if err != nil {
-			mctx.ModuleErrorf("%s", err.Error())
-		}
-		if len(targets) > 0 {
-			primaryModules[len(moduleTargets)] = true
-			moduleTargets = append(moduleTargets, targets...)
+		// A class may contain targets for more than one OS, for example linux and
+		// linux_bionic, or native bridge targets, select the targets for each group separately.
+		for _, osTargets := range targetsByOs(targets) {
+			osTargets, err := decodeMultilib(multilib, osTargets, prefer32)
+			if err != nil {
+				mctx.ModuleErrorf("%s", err.Error())
+			}
+			if len(osTargets) > 0 {
+				primaryModules[len(moduleTargets)] = true
+				moduleTargets = append(moduleTargets, osTargets...)
+			}
 		}
 	}
 
@@ -421,6 +458,9 @@ func createArchType(props reflect.Type) reflect.Type {
 		for _, feature := range archFeatures[arch] {
 			variants = append(variants, proptools.FieldNameForProperty(feature))
 		}
+		for _, name := range getArchPropertyNames(arch) {
+			variants = append(variants, proptools.FieldNameForProperty(name))
+		}
 
 		fields := variantFields(variants)
 
@@ -494,15 +534,7 @@ func InitArchModule(m Module,
 				propertiesValue.Interface()))
 		}
 
-		archPropType := archPropTypeMap.Once(t, func() interface{} {
-			return createArchType(t)
-		})
-
-		if archPropType != nil {
-			base.archProperties = append(base.archProperties, reflect.New(archPropType.(reflect.Type)).Interface())
-		} else {
-			base.archProperties = append(base.archProperties, nil)
-		}
+		base.archProperties = append(base.archProperties, newArchProperties(t))
 	}
 
 	var allProperties []interface{}
@@ -518,6 +550,19 @@ func InitArchModule(m Module,
 	return m, allProperties
 }
 
+// newArchProperties returns a new arch specific property struct for the property struct type t, or
+// nil if t has no arch specific properties.
+func newArchProperties(t reflect.Type) interface{} {
+	archPropType := archPropTypeMap.Once(t, func() interface{} {
+		return createArchType(t)
+	})
+
+	if archPropType == nil {
+		return nil
+	}
+	return reflect.New(archPropType.(reflect.Type)).Interface()
+}
+
 var variantReplacer = strings.NewReplacer("-", "_", ".", "_")
 
 func (a *ModuleBase) appendProperties(ctx BottomUpMutatorContext,
@@ -558,6 +603,19 @@ func (a *ModuleBase) appendProperties(ctx BottomUpMutatorContext,
 }
 
 // Rewrite the module's properties structs to contain arch-specific values.
+// configVariantNames returns the property names for an arch or cpu variant: the name of its base
+// variant followed by its own name if it was declared in the arch variants file.
+func configVariantNames(variant string, config *ConfigArchVariant) []string {
+	var ret []string
+	if config != nil && config.Base != "" {
+		ret = append(ret, variantReplacer.Replace(config.Base))
+	}
+	if variant != "" {
+		ret = append(ret, variantReplacer.Replace(variant))
+	}
+	return ret
+}
+
 func (a *ModuleBase) setArchProperties(ctx BottomUpMutatorContext) {
 	arch := a.Arch()
 	os := a.Os()
@@ -595,8 +653,8 @@ func (a *ModuleBase) setArchProperties(ctx BottomUpMutatorContext) {
 		//         key: value,
 		//     },
 		// },
-		v := variantReplacer.Replace(arch.ArchVariant)
-		if v != "" {
+		// Variants declared in the arch variants file also use the properties of their base variant.
+		for _, v := range configVariantNames(arch.ArchVariant, arch.ConfigArchVariant) {
 			field := proptools.FieldNameForProperty(v)
 			prefix := "arch." + t.Name + "." + v
 			a.appendProperties(ctx, genProps, archStruct, field, prefix)
@@ -608,8 +666,7 @@ func (a *ModuleBase) setArchProperties(ctx BottomUpMutatorContext) {
 		//         key: value,
 		//     },
 		// },
-		c := variantReplacer.Replace(arch.CpuVariant)
-		if c != "" {
+		for _, c := range configVariantNames(arch.CpuVariant, arch.ConfigCpuVariant) {
 			field := proptools.FieldNameForProperty(c)
 			prefix := "arch." + t.Name + "." + c
 			a.appendProperties(ctx, genProps, archStruct, field, prefix)
@@ -730,6 +787,38 @@ func forEachInterface(v reflect.Value, f func(reflect.Value)) {
 	}
 }
 
+// targetsByOs splits a list of targets into a list of targets for each OS, in the order the OSes
+// first appear.  Native bridge targets are kept separate from the other targets for the same OS.
+func targetsByOs(targets []Target) [][]Target {
+	type key struct {
+		os           OsType
+		nativeBridge bool
+	}
+	var ret [][]Target
+	index := make(map[key]int)
+	for _, target := range targets {
+		k := key{target.Os, target.NativeBridge}
+		i, ok := index[k]
+		if !ok {
+			i = len(ret)
+			index[k] = i
+			ret = append(ret, nil)
+		}
+		ret[i] = append(ret[i], target)
+	}
+	return ret
+}
+
+func filterNativeBridgeTargets(targets []Target) []Target {
+	var ret []Target
+	for _, target := range targets {
+		if !target.NativeBridge {
+			ret = append(ret, target)
+		}
+	}
+	return ret
+}
+
 // Convert the arch product variables into a list of targets for each os class structs
 func decodeTargetProductVariables(config *config) (map[OsClass][]Target, error) {
 	variables := config.ProductVariables
@@ -742,7 +831,7 @@ func decodeTargetProductVariables(config *config) (map[OsClass][]Target, error)
 			return
 		}
 
-		arch, err := decodeArch(archName, archVariant, cpuVariant, abi)
+		arch, err := decodeArch(config.archVariants, archName, archVariant, cpuVariant, abi)
 		if err != nil {
 			targetErr = err
 			return
@@ -765,6 +854,17 @@ func decodeTargetProductVariables(config *config) (map[OsClass][]Target, error)
 		addTarget(BuildOs, *variables.HostSecondaryArch, nil, nil, nil)
 	}
 
+	// linux_bionic and linux_musl are additional host targets, built on Linux hosts only for
+	// modules that enable them with target: { linux_bionic: { enabled: true } }.
+	if BuildOs == Linux {
+		if Bool(variables.HostBionic) {
+			addTarget(LinuxBionic, "x86_64", nil, nil, nil)
+		}
+		if Bool(variables.HostMusl) {
+			addTarget(LinuxMusl, "x86_64", nil, nil, nil)
+		}
+	}
+
 	if variables.CrossHost != nil && *variables.CrossHost != "" {
 		crossHostOs := osByName(*variables.CrossHost)
 		if crossHostOs == NoOsType {
@@ -796,6 +896,35 @@ func decodeTargetProductVariables(config *config) (map[OsClass][]Target, error)
 				deviceArches[1].Arch.Native = false
 			}
 		}
+
+		addNativeBridgeTarget := func(archName string, archVariant, cpuVariant *string,
+			abi *[]string, relativePath *string) {
+
+			addTarget(Android, archName, archVariant, cpuVariant, abi)
+			if targetErr != nil {
+				return
+			}
+
+			target := &targets[Device][len(targets[Device])-1]
+			target.Arch.Native = false
+			target.NativeBridge = true
+			target.NativeBridgeRelativePath = archName
+			if relativePath != nil && *relativePath != "" {
+				target.NativeBridgeRelativePath = *relativePath
+			}
+		}
+
+		if variables.NativeBridgeArch != nil && *variables.NativeBridgeArch != "" {
+			addNativeBridgeTarget(*variables.NativeBridgeArch, variables.NativeBridgeArchVariant,
+				variables.NativeBridgeCpuVariant, variables.NativeBridgeAbi,
+				variables.NativeBridgeRelativePath)
+
+			if variables.NativeBridgeSecondaryArch != nil && *variables.NativeBridgeSecondaryArch != "" {
+				addNativeBridgeTarget(*variables.NativeBridgeSecondaryArch,
+					variables.NativeBridgeSecondaryArchVariant, variables.NativeBridgeSecondaryCpuVariant,
+					variables.NativeBridgeSecondaryAbi, variables.NativeBridgeSecondaryRelativePath)
+			}
+		}
 	}
 
 	if targetErr != nil {
@@ -841,6 +970,8 @@ func getMegaDeviceConfig() []archConfig {
 		// mips64r2 is mismatching 64r2 and 64r6 libraries during linking to libgcc
 		//{"mips64", "mips64r2", "", []string{"mips64"}},
 		{"mips64", "mips64r6", "", []string{"mips64"}},
+		{"riscv64", "rv64gc", "", []string{"riscv64"}},
+		{"riscv64", "rv64gcv", "", []string{"riscv64"}},
 		{"x86", "", "", []string{"x86"}},
 		{"x86", "atom", "", []string{"x86"}},
 		{"x86", "haswell", "", []string{"x86"}},
@@ -870,8 +1001,9 @@ func getNdkAbisConfig() []archConfig {
 func decodeArchSettings(archConfigs []archConfig) ([]Target, error) {
 	var ret []Target
 
+	r := newArchVariantRegistrations()
 	for _, config := range archConfigs {
-		arch, err := decodeArch(config.arch, &config.archVariant,
+		arch, err := decodeArch(r, config.arch, &config.archVariant,
 			&config.cpuVariant, &config.abi)
 		if err != nil {
 			return nil, err
@@ -886,8 +1018,10 @@ func decodeArchSettings(archConfigs []archConfig) ([]Target, error) {
 	return ret, nil
 }
 
-// Convert a set of strings from product variables into a single Arch struct
-func decodeArch(arch string, archVariant, cpuVariant *string, abi *[]string) (Arch, error) {
+// Convert a set of strings from product variables into a single Arch struct, using the variants
+// and features in r
+func decodeArch(r *archVariantRegistrations, arch string, archVariant, cpuVariant *string,
+	abi *[]string) (Arch, error) {
 	stringPtr := func(p *string) string {
 		if p != nil {
 			return *p
@@ -930,10 +1064,24 @@ func decodeArch(arch string, archVariant, cpuVariant *string, abi *[]string) (Ar
 		}
 	}
 
-	if featureMap, ok := archFeatureMap[archType]; ok {
+	if featureMap, ok := r.archFeatureMap[archType]; ok {
 		a.ArchFeatures = featureMap[a.ArchVariant]
 	}
 
+	if v, ok := r.configArchVariants[archType][a.ArchVariant]; ok {
+		a.ConfigArchVariant = &v
+	}
+	if v, ok := r.configCpuVariants[archType][a.CpuVariant]; ok {
+		a.ConfigCpuVariant = &v
+	}
+
+	// Cpu variants declared in the arch variants file may add features to the arch variant
+	for _, feature := range r.cpuVariantFeatureMap[archType][a.CpuVariant] {
+		if !inList(feature, a.ArchFeatures) {
+			a.ArchFeatures = append(a.ArchFeatures[:len(a.ArchFeatures):len(a.ArchFeatures)], feature)
+		}
+	}
+
 	return a, nil
 }
 
@@ -0,0 +1,298 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/google/blueprint"
+)
+
+func targetNames(targets []Target) []string {
+	var ret []string
+	for _, target := range targets {
+		ret = append(ret, target.String())
+	}
+	return ret
+}
+
+var decodeTargetProductVariablesTestCases = []struct {
+	name      string
+	variables productVariables
+
+	// Only decode the variables on Linux build hosts
+	linuxOnly bool
+
+	// The expected host and device targets, and the targets selected for each compile_multilib
+	// value
+	host           []string
+	device         []string
+	hostMultilib   map[string][]string
+	deviceMultilib map[string][]string
+}{
+	{
+		name: "riscv64",
+		variables: productVariables{
+			HostArch:          stringPtr("x86_64"),
+			DeviceArch:        stringPtr("riscv64"),
+			DeviceArchVariant: stringPtr("rv64gc"),
+			DeviceAbi:         &[]string{"riscv64"},
+		},
+		host:   []string{BuildOs.String() + "_x86_64"},
+		device: []string{"android_riscv64_rv64gc"},
+		deviceMultilib: map[string][]string{
+			"both":  {"android_riscv64_rv64gc"},
+			"first": {"android_riscv64_rv64gc"},
+			"64":    {"android_riscv64_rv64gc"},
+			"32":    nil,
+		},
+	},
+	{
+		name: "native bridge",
+		variables: productVariables{
+			HostArch:                         stringPtr("x86_64"),
+			DeviceArch:                       stringPtr("x86_64"),
+			DeviceSecondaryArch:              stringPtr("x86"),
+			NativeBridgeArch:                 stringPtr("arm64"),
+			NativeBridgeArchVariant:          stringPtr("armv8-a"),
+			NativeBridgeSecondaryArch:        stringPtr("arm"),
+			NativeBridgeSecondaryArchVariant: stringPtr("armv7-a-neon"),
+		},
+		host: []string{BuildOs.String() + "_x86_64"},
+		device: []string{
+			"android_x86_64",
+			"android_x86",
+			"android_arm64_armv8-a_native_bridge",
+			"android_arm_armv7-a-neon_native_bridge",
+		},
+		deviceMultilib: map[string][]string{
+			// Native bridge targets have their own primary target
+			"first": {"android_x86_64", "android_arm64_armv8-a_native_bridge"},
+			"both": {
+				"android_x86_64",
+				"android_x86",
+				"android_arm64_armv8-a_native_bridge",
+				"android_arm_armv7-a-neon_native_bridge",
+			},
+			"32": {"android_x86", "android_arm_armv7-a-neon_native_bridge"},
+		},
+	},
+	{
+		name: "linux_bionic and linux_musl",
+		variables: productVariables{
+			HostArch:          stringPtr("x86_64"),
+			HostSecondaryArch: stringPtr("x86"),
+			HostBionic:        boolPtr(true),
+			HostMusl:          boolPtr(true),
+		},
+		linuxOnly: true,
+		host: []string{
+			"linux_x86_64",
+			"linux_x86",
+			"linux_bionic_x86_64",
+			"linux_musl_x86_64",
+		},
+		hostMultilib: map[string][]string{
+			// Each host OS has its own primary target
+			"first": {"linux_x86_64", "linux_bionic_x86_64", "linux_musl_x86_64"},
+			"both":  {"linux_x86_64", "linux_x86", "linux_bionic_x86_64", "linux_musl_x86_64"},
+			"32":    {"linux_x86"},
+		},
+	},
+	{
+		name: "linux_musl only",
+		variables: productVariables{
+			HostArch: stringPtr("x86_64"),
+			HostMusl: boolPtr(true),
+		},
+		linuxOnly: true,
+		host:      []string{"linux_x86_64", "linux_musl_x86_64"},
+		hostMultilib: map[string][]string{
+			"first":    {"linux_x86_64", "linux_musl_x86_64"},
+			"prefer32": {"linux_x86_64", "linux_musl_x86_64"},
+		},
+	},
+}
+
+// selectTargets selects the targets for a compile_multilib value separately for each OS, like
+// archMutator does
+func selectTargets(multilib string, targets []Target) ([]Target, error) {
+	var ret []Target
+	for _, osTargets := range targetsByOs(targets) {
+		osTargets, err := decodeMultilib(multilib, osTargets, false)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, osTargets...)
+	}
+	return ret, nil
+}
+
+func TestDecodeTargetProductVariables(t *testing.T) {
+	for _, test := range decodeTargetProductVariablesTestCases {
+		if test.linuxOnly && BuildOs != Linux {
+			continue
+		}
+
+		config := &config{
+			ProductVariables: test.variables,
+			archVariants:     newArchVariantRegistrations(),
+		}
+
+		targets, err := decodeTargetProductVariables(config)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.name, err.Error())
+			continue
+		}
+
+		if host := targetNames(targets[Host]); !reflect.DeepEqual(host, test.host) {
+			t.Errorf("%s: expected host targets %q, got %q", test.name, test.host, host)
+		}
+		if device := targetNames(targets[Device]); !reflect.DeepEqual(device, test.device) {
+			t.Errorf("%s: expected device targets %q, got %q", test.name, test.device, device)
+		}
+
+		checkMultilib := func(class OsClass, multilibs map[string][]string) {
+			for multilib, expected := range multilibs {
+				multilibTargets, err := selectTargets(multilib, targets[class])
+				if err != nil {
+					t.Errorf("%s: unexpected error for multilib %q: %s", test.name, multilib, err.Error())
+					continue
+				}
+				if got := targetNames(multilibTargets); !reflect.DeepEqual(got, expected) {
+					t.Errorf("%s: expected %q targets %q, got %q", test.name, multilib, expected, got)
+				}
+			}
+		}
+		checkMultilib(Host, test.hostMultilib)
+		checkMultilib(Device, test.deviceMultilib)
+	}
+}
+
+func TestNativeBridgeRelativePath(t *testing.T) {
+	config := &config{
+		ProductVariables: productVariables{
+			HostArch:                          stringPtr("x86_64"),
+			DeviceArch:                        stringPtr("x86_64"),
+			NativeBridgeArch:                  stringPtr("arm64"),
+			NativeBridgeSecondaryArch:         stringPtr("arm"),
+			NativeBridgeSecondaryRelativePath: stringPtr("arm/nb"),
+		},
+		archVariants: newArchVariantRegistrations(),
+	}
+
+	targets, err := decodeTargetProductVariables(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var relativePaths []string
+	for _, target := range targets[Device] {
+		if target.NativeBridge {
+			if target.Arch.Native {
+				t.Errorf("expected native bridge target %s not to be native", target)
+			}
+			relativePaths = append(relativePaths, target.NativeBridgeRelativePath)
+		}
+	}
+
+	// The relative path defaults to the arch name
+	if expected := []string{"arm64", "arm/nb"}; !reflect.DeepEqual(relativePaths, expected) {
+		t.Errorf("expected relative paths %q, got %q", expected, relativePaths)
+	}
+}
+
+func TestNativeBridgeVariants(t *testing.T) {
+	buildDir, err := ioutil.TempDir("", "soong_arch_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(buildDir)
+
+	config := TestConfig(buildDir)
+	config.Targets = map[OsClass][]Target{
+		Device: {
+			{Os: Android, Arch: Arch{ArchType: X86_64, Native: true}},
+			{Os: Android, Arch: Arch{ArchType: X86, Native: true}},
+			{Os: Android, Arch: Arch{ArchType: Arm64}, NativeBridge: true, NativeBridgeRelativePath: "arm64"},
+			{Os: Android, Arch: Arch{ArchType: Arm}, NativeBridge: true, NativeBridgeRelativePath: "arm"},
+		},
+	}
+
+	ctx := NewContext()
+	ctx.RegisterModuleType("arch_test", newArchTestModule)
+	ctx.MockFileSystem(map[string][]byte{
+		"Blueprints": []byte(`
+			arch_test {
+				name: "foo",
+				native_bridge_supported: true,
+			}
+
+			arch_test {
+				name: "bar",
+			}
+
+			arch_test {
+				name: "baz",
+				native_bridge_supported: true,
+				compile_multilib: "first",
+			}
+		`),
+	})
+
+	_, errs := ctx.ParseBlueprintsFiles("Blueprints")
+	fail(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+	fail(t, errs)
+
+	variants := make(map[string][]string)
+	ctx.VisitAllModules(func(m blueprint.Module) {
+		if module, ok := m.(*archTestModule); ok {
+			name := ctx.ModuleName(m)
+			variants[name] = append(variants[name], module.Target().String())
+		}
+	})
+
+	expected := map[string][]string{
+		"foo": {"android_arm64_native_bridge", "android_arm_native_bridge", "android_x86", "android_x86_64"},
+		"bar": {"android_x86", "android_x86_64"},
+		"baz": {"android_arm64_native_bridge", "android_x86_64"},
+	}
+	for _, names := range variants {
+		sort.Strings(names)
+	}
+	if !reflect.DeepEqual(variants, expected) {
+		t.Errorf("expected variants %q, got %q", expected, variants)
+	}
+}
+
+type archTestModule struct {
+	ModuleBase
+}
+
+func newArchTestModule() (blueprint.Module, []interface{}) {
+	m := &archTestModule{}
+	return InitAndroidArchModule(m, DeviceSupported, MultilibBoth)
+}
+
+func (m *archTestModule) DepsMutator(ctx BottomUpMutatorContext) {
+}
+
+func (m *archTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+}
@@ -0,0 +1,269 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// This file implements arch variants, cpu variants and arch features declared by a product in a
+// JSON file instead of through RegisterArchVariants and RegisterArchFeatures calls in Go.  The
+// file is named by the ArchVariantsFile product variable, and contains an object for each arch:
+//
+// {
+//     "arm64": {
+//         "Features": ["dotprod"],
+//         "Cpu_variants": {
+//             "cortex-a75": {
+//                 "Base": "cortex-a53",
+//                 "Features": ["dotprod"],
+//                 "Cflags": ["-mcpu=cortex-a75"]
+//             }
+//         }
+//     }
+// }
+//
+// The variants can be used in the DeviceArchVariant and DeviceCpuVariant product variables and in
+// arch: { arm64: { cortex_a75: { ... } } } properties the same way as the variants registered in
+// Go.  Modules built for a declared variant also use the properties of its base variant.  The
+// declarations are stored in the config, so they only affect the targets of that config.
+
+// ConfigArchVariant describes an arch or cpu variant declared in the arch variants file.
+type ConfigArchVariant struct {
+	// Base is the variant registered in Go whose compiler flags and arch properties are used for
+	// this variant, or empty to use the generic flags for the arch.
+	Base string `json:",omitempty"`
+
+	// Features lists the arch features supported by this variant.
+	Features []string `json:",omitempty"`
+
+	// Cflags are added to the compiler flags of the base variant.
+	Cflags []string `json:",omitempty"`
+}
+
+type configArchType struct {
+	// Features lists new arch features that may be used by the variants.
+	Features []string `json:",omitempty"`
+
+	Arch_variants map[string]ConfigArchVariant `json:",omitempty"`
+	Cpu_variants  map[string]ConfigArchVariant `json:",omitempty"`
+}
+
+// archVariantRegistrations holds the arch variants, cpu variants and arch features of a config:
+// the ones registered in Go and the ones declared in its arch variants file.
+type archVariantRegistrations struct {
+	archVariants         map[ArchType][]string
+	archFeatures         map[ArchType][]string
+	archFeatureMap       map[ArchType]map[string][]string
+	cpuVariantFeatureMap map[ArchType]map[string][]string
+	configArchVariants   map[ArchType]map[string]ConfigArchVariant
+	configCpuVariants    map[ArchType]map[string]ConfigArchVariant
+}
+
+// newArchVariantRegistrations returns the registrations made in Go, which the arch variants file
+// of a config can add to without affecting other configs.
+func newArchVariantRegistrations() *archVariantRegistrations {
+	r := &archVariantRegistrations{
+		archVariants:         make(map[ArchType][]string),
+		archFeatures:         make(map[ArchType][]string),
+		archFeatureMap:       make(map[ArchType]map[string][]string),
+		cpuVariantFeatureMap: make(map[ArchType]map[string][]string),
+		configArchVariants:   make(map[ArchType]map[string]ConfigArchVariant),
+		configCpuVariants:    make(map[ArchType]map[string]ConfigArchVariant),
+	}
+	for archType, variants := range archVariants {
+		r.archVariants[archType] = append([]string(nil), variants...)
+	}
+	for archType, features := range archFeatures {
+		r.archFeatures[archType] = append([]string(nil), features...)
+	}
+	for archType, featureMap := range archFeatureMap {
+		r.archFeatureMap[archType] = make(map[string][]string, len(featureMap))
+		for variant, features := range featureMap {
+			r.archFeatureMap[archType][variant] = features
+		}
+	}
+	return r
+}
+
+// The arch property structs are created once per process, before any module is created, so they
+// have fields for the variants and features declared in the arch variants files of all configs.
+// Which of them apply to a module only depends on the registrations of its config.
+var archPropertyNamesLock sync.Mutex
+var archPropertyNames = map[ArchType][]string{}
+
+func addArchPropertyNames(archType ArchType, names ...string) {
+	archPropertyNamesLock.Lock()
+	defer archPropertyNamesLock.Unlock()
+	for _, name := range names {
+		if !inList(name, archPropertyNames[archType]) {
+			archPropertyNames[archType] = append(archPropertyNames[archType], name)
+		}
+	}
+}
+
+func getArchPropertyNames(archType ArchType) []string {
+	archPropertyNamesLock.Lock()
+	defer archPropertyNamesLock.Unlock()
+	return archPropertyNames[archType]
+}
+
+// BaseVariants returns a copy of the arch with the variants declared in the arch variants file
+// replaced by the variants registered in Go that they are based on.  Code that checks for specific
+// variants, for example to select compiler flags, should check the base variants.
+func (a Arch) BaseVariants() Arch {
+	if a.ConfigArchVariant != nil {
+		a.ArchVariant = a.ConfigArchVariant.Base
+	}
+	if a.ConfigCpuVariant != nil {
+		a.CpuVariant = a.ConfigCpuVariant.Base
+	}
+	a.ConfigArchVariant = nil
+	a.ConfigCpuVariant = nil
+	return a
+}
+
+func loadArchVariantsFile(filename string) (map[string]configArchType, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ret map[string]configArchType
+	err = json.NewDecoder(f).Decode(&ret)
+	if err != nil {
+		return nil, fmt.Errorf("arch variants file: %s did not parse correctly: %s", filename, err.Error())
+	}
+
+	return ret, nil
+}
+
+// registerArchVariants adds the variants and features declared in the arch variants file to r.
+// Nothing is registered if any of the declarations is invalid.
+func registerArchVariants(r *archVariantRegistrations, archTypes map[string]configArchType) error {
+	var archNames []string
+	for name := range archTypes {
+		archNames = append(archNames, name)
+	}
+	sort.Strings(archNames)
+
+	for _, name := range archNames {
+		archType, ok := archTypeMap[name]
+		if !ok {
+			return fmt.Errorf("arch variants file: unknown arch %q", name)
+		}
+		if err := r.checkConfigArchType(archType, archTypes[name]); err != nil {
+			return fmt.Errorf("arch variants file: arch %q: %s", name, err.Error())
+		}
+	}
+
+	for _, name := range archNames {
+		archType := archTypeMap[name]
+		config := archTypes[name]
+
+		r.archFeatures[archType] = append(r.archFeatures[archType], config.Features...)
+		addArchPropertyNames(archType, config.Features...)
+
+		for _, variant := range sortedVariantNames(config.Arch_variants) {
+			v := config.Arch_variants[variant]
+			r.archVariants[archType] = append(r.archVariants[archType], variantReplacer.Replace(variant))
+			addArchPropertyNames(archType, variantReplacer.Replace(variant))
+			if r.configArchVariants[archType] == nil {
+				r.configArchVariants[archType] = make(map[string]ConfigArchVariant)
+			}
+			r.configArchVariants[archType][variant] = v
+
+			if r.archFeatureMap[archType] == nil {
+				r.archFeatureMap[archType] = make(map[string][]string)
+			}
+			features := append([]string(nil), r.archFeatureMap[archType][v.Base]...)
+			r.archFeatureMap[archType][variant] = append(features, v.Features...)
+		}
+
+		for _, variant := range sortedVariantNames(config.Cpu_variants) {
+			v := config.Cpu_variants[variant]
+			r.archVariants[archType] = append(r.archVariants[archType], variantReplacer.Replace(variant))
+			addArchPropertyNames(archType, variantReplacer.Replace(variant))
+			if r.configCpuVariants[archType] == nil {
+				r.configCpuVariants[archType] = make(map[string]ConfigArchVariant)
+			}
+			r.configCpuVariants[archType][variant] = v
+
+			if r.cpuVariantFeatureMap[archType] == nil {
+				r.cpuVariantFeatureMap[archType] = make(map[string][]string)
+			}
+			r.cpuVariantFeatureMap[archType][variant] = v.Features
+		}
+	}
+
+	return nil
+}
+
+func sortedVariantNames(variants map[string]ConfigArchVariant) []string {
+	var ret []string
+	for variant := range variants {
+		ret = append(ret, variant)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func (r *archVariantRegistrations) checkConfigArchType(archType ArchType, config configArchType) error {
+	for _, feature := range config.Features {
+		if inList(feature, r.archFeatures[archType]) || inList(feature, r.archVariants[archType]) {
+			return fmt.Errorf("feature %q is already registered", feature)
+		}
+	}
+
+	checkVariant := func(kind, variant string, v ConfigArchVariant) error {
+		if variant == "" || variant == "generic" || variant == archType.Name {
+			return fmt.Errorf("invalid %s variant name %q", kind, variant)
+		}
+		if inList(variantReplacer.Replace(variant), r.archVariants[archType]) ||
+			inList(variant, r.archFeatures[archType]) || inList(variant, config.Features) {
+			return fmt.Errorf("%s variant %q is already registered", kind, variant)
+		}
+		if v.Base != "" && !inList(variantReplacer.Replace(v.Base), r.archVariants[archType]) {
+			return fmt.Errorf("%s variant %q: unknown base variant %q", kind, variant, v.Base)
+		}
+		for _, feature := range v.Features {
+			if !inList(feature, r.archFeatures[archType]) && !inList(feature, config.Features) {
+				return fmt.Errorf("%s variant %q: unknown feature %q", kind, variant, feature)
+			}
+		}
+		return nil
+	}
+
+	for _, variant := range sortedVariantNames(config.Arch_variants) {
+		if err := checkVariant("arch", variant, config.Arch_variants[variant]); err != nil {
+			return err
+		}
+		if _, ok := config.Cpu_variants[variant]; ok {
+			return fmt.Errorf("%q is declared as both an arch variant and a cpu variant", variant)
+		}
+	}
+	for _, variant := range sortedVariantNames(config.Cpu_variants) {
+		if err := checkVariant("cpu", variant, config.Cpu_variants[variant]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,205 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"reflect"
+	"testing"
+)
+
+var registerArchVariantsTestCases = []struct {
+	name      string
+	archTypes map[string]configArchType
+
+	// The arch and cpu variant to decode after a successful registration, the expected features
+	// and the expected base variants
+	arch            string
+	archVariant     string
+	cpuVariant      string
+	features        []string
+	baseArchVariant string
+	baseCpuVariant  string
+
+	err bool
+}{
+	{
+		name: "cpu variant",
+		archTypes: map[string]configArchType{
+			"arm64": {
+				Features: []string{"dotprod"},
+				Cpu_variants: map[string]ConfigArchVariant{
+					"cortex-a75": {
+						Base:     "cortex-a53",
+						Features: []string{"dotprod"},
+						Cflags:   []string{"-mcpu=cortex-a75"},
+					},
+				},
+			},
+		},
+		arch:            "arm64",
+		archVariant:     "armv8_a",
+		cpuVariant:      "cortex-a75",
+		features:        []string{"crc", "dotprod"},
+		baseArchVariant: "armv8_a",
+		baseCpuVariant:  "cortex-a53",
+	},
+	{
+		name: "arch variant",
+		archTypes: map[string]configArchType{
+			"arm64": {
+				Arch_variants: map[string]ConfigArchVariant{
+					"armv8_2a": {
+						Base: "armv8_a",
+					},
+				},
+			},
+		},
+		arch:            "arm64",
+		archVariant:     "armv8_2a",
+		features:        []string{"crc"},
+		baseArchVariant: "armv8_a",
+	},
+
+	// Errors
+	{
+		name: "unknown arch",
+		archTypes: map[string]configArchType{
+			"sparc": {},
+		},
+		err: true,
+	},
+	{
+		name: "duplicate variant",
+		archTypes: map[string]configArchType{
+			"arm64": {
+				Cpu_variants: map[string]ConfigArchVariant{
+					"cortex-a53": {},
+				},
+			},
+		},
+		err: true,
+	},
+	{
+		name: "unknown base",
+		archTypes: map[string]configArchType{
+			"arm64": {
+				Cpu_variants: map[string]ConfigArchVariant{
+					"kryo": {Base: "cortex-a72"},
+				},
+			},
+		},
+		err: true,
+	},
+	{
+		name: "unknown feature",
+		archTypes: map[string]configArchType{
+			"arm64": {
+				Cpu_variants: map[string]ConfigArchVariant{
+					"kryo": {Features: []string{"sve"}},
+				},
+			},
+		},
+		err: true,
+	},
+	{
+		name: "arch and cpu variant",
+		archTypes: map[string]configArchType{
+			"arm64": {
+				Arch_variants: map[string]ConfigArchVariant{
+					"kryo": {},
+				},
+				Cpu_variants: map[string]ConfigArchVariant{
+					"kryo": {},
+				},
+			},
+		},
+		err: true,
+	},
+}
+
+func TestRegisterArchVariants(t *testing.T) {
+	oldArchVariants, oldArchFeatures, oldArchFeatureMap := archVariants, archFeatures, archFeatureMap
+	defer func() {
+		archVariants, archFeatures, archFeatureMap = oldArchVariants, oldArchFeatures, oldArchFeatureMap
+	}()
+
+	archVariants = map[ArchType][]string{Arm64: {"armv8_a", "cortex_a53"}}
+	archFeatures = map[ArchType][]string{Arm64: {"crc"}}
+	archFeatureMap = map[ArchType]map[string][]string{Arm64: {"armv8_a": {"crc"}}}
+
+	for _, test := range registerArchVariantsTestCases {
+		// Register twice in separate registrations, like two configs would
+		for i := 0; i < 2; i++ {
+			r := newArchVariantRegistrations()
+			err := registerArchVariants(r, test.archTypes)
+			if err != nil {
+				if !test.err {
+					t.Errorf("%s: unexpected error %s", test.name, err.Error())
+				}
+				break
+			} else if test.err {
+				t.Errorf("%s: expected error", test.name)
+				break
+			}
+
+			arch, err := decodeArch(r, test.arch, &test.archVariant, &test.cpuVariant, nil)
+			if err != nil {
+				t.Errorf("%s: unexpected error %s", test.name, err.Error())
+				break
+			}
+			if !reflect.DeepEqual(arch.ArchFeatures, test.features) {
+				t.Errorf("%s: expected features %q, got %q", test.name, test.features, arch.ArchFeatures)
+			}
+			if arch.ConfigArchVariant == nil && arch.ConfigCpuVariant == nil {
+				t.Errorf("%s: variants were not registered", test.name)
+			}
+
+			base := arch.BaseVariants()
+			if base.ArchVariant != test.baseArchVariant || base.CpuVariant != test.baseCpuVariant {
+				t.Errorf("%s: expected base variants %q and %q, got %q and %q", test.name,
+					test.baseArchVariant, test.baseCpuVariant, base.ArchVariant, base.CpuVariant)
+			}
+		}
+
+		if !reflect.DeepEqual(archVariants, map[ArchType][]string{Arm64: {"armv8_a", "cortex_a53"}}) ||
+			!reflect.DeepEqual(archFeatures, map[ArchType][]string{Arm64: {"crc"}}) {
+			t.Errorf("%s: variants registered in Go were modified", test.name)
+		}
+	}
+}
+
+func TestConfigVariantNames(t *testing.T) {
+	testCases := []struct {
+		variant  string
+		config   *ConfigArchVariant
+		expected []string
+	}{
+		{variant: ""},
+		{variant: "cortex-a53", expected: []string{"cortex_a53"}},
+		{variant: "cortex-a75", config: &ConfigArchVariant{}, expected: []string{"cortex_a75"}},
+		{
+			variant:  "cortex-a75",
+			config:   &ConfigArchVariant{Base: "cortex-a53"},
+			expected: []string{"cortex_a53", "cortex_a75"},
+		},
+	}
+
+	for _, test := range testCases {
+		got := configVariantNames(test.variant, test.config)
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%q: expected %q, got %q", test.variant, test.expected, got)
+		}
+	}
+}
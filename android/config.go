@@ -20,7 +20,9 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -29,6 +31,7 @@ import (
 )
 
 var Bool = proptools.Bool
+var String = proptools.String
 
 // The configuration file name
 const configFileName = "soong.config"
@@ -64,11 +67,31 @@ type config struct {
 	ConfigFileName           string
 	ProductVariablesFileName string
 
+	// Product variables files inherited by the product variables file
+	InheritedProductVariablesFileNames []string
+
+	// Set if the product declares arch variants in a JSON file, see ArchVariantsFile
+	ArchVariantsFileName string
+
+	// The arch variants and features registered in Go and declared in the arch variants file
+	archVariants *archVariantRegistrations
+
+	// The type of the product_variables property struct for the product's custom variables, nil
+	// if it has none.  Module types in contexts created by NewContextForConfig add it to their
+	// modules.
+	customVariablePropertiesType reflect.Type
+
+	// If set, moduleGraphSingleton writes a JSON description of the module graph to this file
+	ModuleGraphFile string
+
 	Targets        map[OsClass][]Target
 	BuildOsVariant string
 
 	deviceConfig *deviceConfig
 
+	// Set by OpenDiagnosticsStream
+	diagnostics *diagnosticsSink
+
 	srcDir   string // the path of the root source directory
 	buildDir string // the path of the build output directory
 
@@ -76,6 +99,11 @@ type config struct {
 	envDeps   map[string]string
 	envFrozen bool
 
+	// The modules or call sites that read each environment variable, and the ones that read a
+	// variable that is not allowed by EnvAllowlist or EnvDenylist
+	envReaders    map[string]map[string]bool
+	envViolations map[string]map[string]bool
+
 	inMake bool
 
 	OncePer
@@ -97,7 +125,20 @@ func loadConfig(config *config) error {
 		return err
 	}
 
-	return loadFromConfigFile(&config.ProductVariables, config.ProductVariablesFileName)
+	err = loadFromConfigFile(&config.ProductVariables, config.ProductVariablesFileName)
+	if err != nil {
+		return err
+	}
+
+	config.InheritedProductVariablesFileNames, err =
+		resolveProductVariablesInherits(&config.ProductVariables, config.srcDir)
+	if err != nil {
+		return err
+	}
+
+	// Write out the merged product variables for debugging
+	return writeResolvedProductVariables(&config.ProductVariables,
+		filepath.Join(config.buildDir, resolvedProductVariablesFileName))
 }
 
 // loads configuration options from a JSON file in the cwd.
@@ -116,11 +157,13 @@ func loadFromConfigFile(configurable jsonConfigurable, filename string) error {
 			return err
 		}
 	} else {
-		// Make a decoder for it
-		jsonDecoder := json.NewDecoder(configFileReader)
-		err = jsonDecoder.Decode(configurable)
+		data, err := ioutil.ReadAll(configFileReader)
+		if err != nil {
+			return fmt.Errorf("config file: %s could not be read: %s", filename, err.Error())
+		}
+		err = decodeConfigStrict(filename, data, configurable)
 		if err != nil {
-			return fmt.Errorf("config file: %s did not parse correctly: "+err.Error(), filename)
+			return fmt.Errorf("config file: %s", err.Error())
 		}
 	}
 
@@ -131,9 +174,9 @@ func loadFromConfigFile(configurable jsonConfigurable, filename string) error {
 // atomically writes the config file in case two copies of soong_build are running simultaneously
 // (for example, docs generation and ninja manifest generation)
 func saveToConfigFile(config jsonConfigurable, filename string) error {
-	data, err := json.MarshalIndent(&config, "", "    ")
+	data, err := configFileData(config)
 	if err != nil {
-		return fmt.Errorf("cannot marshal config data: %s", err.Error())
+		return err
 	}
 
 	f, err := ioutil.TempFile(filepath.Dir(filename), "config")
@@ -148,21 +191,29 @@ func saveToConfigFile(config jsonConfigurable, filename string) error {
 		return fmt.Errorf("default config file: %s could not be written: %s", filename, err.Error())
 	}
 
-	_, err = f.WriteString("\n")
-	if err != nil {
-		return fmt.Errorf("default config file: %s could not be written: %s", filename, err.Error())
-	}
-
 	f.Close()
 	os.Rename(f.Name(), filename)
 
 	return nil
 }
 
+// configFileData returns the contents of a config file written by saveToConfigFile.
+func configFileData(config jsonConfigurable) ([]byte, error) {
+	data, err := json.MarshalIndent(&config, "", "    ")
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal config data: %s", err.Error())
+	}
+	return append(data, '\n'), nil
+}
+
 // TestConfig returns a Config object suitable for using for tests
 func TestConfig(buildDir string) Config {
 	return Config{&config{
 		buildDir: buildDir,
+		envDeps:  make(map[string]string),
+
+		envReaders:    make(map[string]map[string]bool),
+		envViolations: make(map[string]map[string]bool),
 	}}
 }
 
@@ -178,6 +229,9 @@ func NewConfig(srcDir, buildDir string) (Config, error) {
 		buildDir: buildDir,
 		envDeps:  make(map[string]string),
 
+		envReaders:    make(map[string]map[string]bool),
+		envViolations: make(map[string]map[string]bool),
+
 		deviceConfig: &deviceConfig{},
 	}
 
@@ -209,6 +263,37 @@ func NewConfig(srcDir, buildDir string) (Config, error) {
 		return Config{}, err
 	}
 
+	config.customVariablePropertiesType, err = newCustomVariablePropertiesType(
+		config.ProductVariables.Custom_variables)
+	if err != nil {
+		return Config{}, err
+	}
+
+	config.archVariants = newArchVariantRegistrations()
+	if file := String(config.ProductVariables.ArchVariantsFile); file != "" {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(srcDir, file)
+		}
+		config.ArchVariantsFileName = file
+
+		archTypes, err := loadArchVariantsFile(file)
+		if err != nil {
+			return Config{}, err
+		}
+		err = registerArchVariants(config.archVariants, archTypes)
+		if err != nil {
+			return Config{}, err
+		}
+	}
+
+	if errs := validateProductVariables(config.archVariants, &config.ProductVariables); len(errs) > 0 {
+		var msgs []string
+		for _, err := range errs {
+			msgs = append(msgs, err.Error())
+		}
+		return Config{}, fmt.Errorf("%s:\n%s", config.ProductVariablesFileName, strings.Join(msgs, "\n"))
+	}
+
 	inMakeFile := filepath.Join(buildDir, ".soong.in_make")
 	if _, err := os.Stat(inMakeFile); err == nil {
 		config.inMake = true
@@ -276,10 +361,26 @@ func (c *config) CpPreserveSymlinksFlags() string {
 	}
 }
 
+// Getenv returns the value of an environment variable and records that the build manifest depends
+// on it.  Modules should use ModuleContext.Getenv instead so that the module is recorded as the
+// reader of the variable.
 func (c *config) Getenv(key string) string {
+	return c.getenv(key, envCallSite())
+}
+
+func (c *config) getenv(key, reader string) string {
 	var val string
 	var exists bool
 	c.envLock.Lock()
+	defer c.envLock.Unlock()
+
+	if !c.envAllowed(key) {
+		// Don't add a dependency on the variable, it will be reported as an error by the
+		// env singleton
+		addEnvReader(c.envViolations, key, reader)
+		return ""
+	}
+
 	if val, exists = c.envDeps[key]; !exists {
 		if c.envFrozen {
 			panic("Cannot access new environment variables after envdeps are frozen")
@@ -287,10 +388,62 @@ func (c *config) Getenv(key string) string {
 		val = os.Getenv(key)
 		c.envDeps[key] = val
 	}
-	c.envLock.Unlock()
+	if !c.envFrozen {
+		addEnvReader(c.envReaders, key, reader)
+	}
 	return val
 }
 
+// envAllowed returns false if the EnvAllowlist product variable is set and doesn't contain key, or
+// if the EnvDenylist product variable contains key.
+func (c *config) envAllowed(key string) bool {
+	if c.ProductVariables.EnvAllowlist != nil && !inList(key, *c.ProductVariables.EnvAllowlist) {
+		return false
+	}
+	return !inList(key, c.ProductVariables.EnvDenylist)
+}
+
+// addEnvReader adds reader to the set of readers of key.  Every module variant that calls
+// ModuleContext.Getenv is a reader, so this must not be linear in the number of readers.
+func addEnvReader(readers map[string]map[string]bool, key, reader string) {
+	if readers[key] == nil {
+		readers[key] = make(map[string]bool)
+	}
+	readers[key][reader] = true
+}
+
+// sortedEnvReaders returns the readers of each key as a sorted list.
+func sortedEnvReaders(readers map[string]map[string]bool) map[string][]string {
+	ret := make(map[string][]string, len(readers))
+	for key, set := range readers {
+		list := make([]string, 0, len(set))
+		for reader := range set {
+			list = append(list, reader)
+		}
+		sort.Strings(list)
+		ret[key] = list
+	}
+	return ret
+}
+
+// envCallSite returns the function and source location that called into Getenv, skipping the
+// config methods that wrap it.
+func envCallSite() string {
+	pc := make([]uintptr, 10)
+	n := runtime.Callers(3, pc)
+	frames := runtime.CallersFrames(pc[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "android/soong/android.(*config).") &&
+			!strings.HasPrefix(frame.Function, "android/soong/android.Config.") {
+			return fmt.Sprintf("%s (%s:%d)", frame.Function, filepath.Base(frame.File), frame.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
 func (c *config) GetenvWithDefault(key string, defaultValue string) string {
 	ret := c.Getenv(key)
 	if ret == "" {
@@ -316,6 +469,28 @@ func (c *config) EnvDeps() map[string]string {
 	return c.envDeps
 }
 
+// EnvReaders returns the sorted modules or call sites that read each environment variable.  It must
+// only be called after EnvDeps.
+func (c *config) EnvReaders() map[string][]string {
+	c.envLock.Lock()
+	defer c.envLock.Unlock()
+	return sortedEnvReaders(c.envReaders)
+}
+
+// EnvViolations returns the sorted modules or call sites that read each environment variable that is not
+// allowed by the EnvAllowlist or EnvDenylist product variables.
+func (c *config) EnvViolations() map[string][]string {
+	c.envLock.Lock()
+	defer c.envLock.Unlock()
+	return sortedEnvReaders(c.envViolations)
+}
+
+// HermeticChecks returns true if the inputs and arguments of build statements should be checked
+// for undeclared dependencies on source files.
+func (c *config) HermeticChecks() bool {
+	return c.IsEnvTrue("SOONG_HERMETIC_CHECKS")
+}
+
 func (c *config) EmbeddedInMake() bool {
 	return c.inMake
 }
@@ -397,9 +572,20 @@ func (c *config) SanitizeDeviceArch() []string {
 	return append([]string(nil), c.ProductVariables.SanitizeDeviceArch...)
 }
 
+// WarningsAsErrors returns true if warnings for modules in dir should be reported as errors.
+func (c *config) WarningsAsErrors(dir string) bool {
+	for _, d := range c.ProductVariables.WarningsAsErrorsDirs {
+		d = filepath.Clean(d)
+		if d == "." || dir == d || strings.HasPrefix(dir, d+"/") {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *config) Android64() bool {
 	for _, t := range c.Targets[Device] {
-		if t.Arch.ArchType.Multilib == "lib64" {
+		if t.Arch.ArchType.Multilib == "lib64" && !t.NativeBridge {
 			return true
 		}
 	}
@@ -23,6 +23,10 @@ type defaultsDependencyTag struct {
 	blueprint.BaseDependencyTag
 }
 
+func (defaultsDependencyTag) String() string {
+	return "defaults"
+}
+
 var DefaultsDepTag defaultsDependencyTag
 
 type defaultsProperties struct {
@@ -42,9 +46,14 @@ func (d *DefaultableModule) setProperties(props []interface{}) {
 	d.defaultableProperties = props
 }
 
+func (d *DefaultableModule) addProperties(props ...interface{}) {
+	d.defaultableProperties = append(d.defaultableProperties, props...)
+}
+
 type Defaultable interface {
 	defaults() *defaultsProperties
 	setProperties([]interface{})
+	addProperties(...interface{})
 	applyDefaults(TopDownMutatorContext, []Defaults)
 }
 
@@ -0,0 +1,429 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	bpparser "github.com/google/blueprint/parser"
+)
+
+// This file implements a sink for the errors reported by modules through ModuleErrorf and
+// PropertyErrorf, which writes them to a JSON or SARIF file in addition to the normal output on
+// stderr.  Blueprint exits the process as soon as a mutator or build action generation pass
+// reports an error, so soong_build can't write the file at the end of its own run.  Instead,
+// RunWithDiagnostics runs soong_build again as a child process, which appends each diagnostic as
+// a line of JSON to a stream file, and writes the diagnostics file once after the child exits.
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// The environment variable that passes the stream file to the child soong_build process
+const diagnosticsStreamEnv = "SOONG_DIAGNOSTICS_STREAM"
+
+type Diagnostic struct {
+	Module   string
+	Variant  string `json:",omitempty"`
+	Property string `json:",omitempty"`
+	File     string
+	Line     int `json:",omitempty"`
+	Column   int `json:",omitempty"`
+	Severity string
+	Message  string
+}
+
+type diagnosticsSink struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+func (s *diagnosticsSink) add(d Diagnostic) {
+	data, err := json.Marshal(d)
+	if err != nil {
+		panic(err)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, err := s.w.Write(append(data, '\n')); err != nil {
+		fmt.Fprintln(os.Stderr, "failed to write diagnostics:", err)
+	}
+}
+
+func checkDiagnosticsFormat(format string) error {
+	switch format {
+	case "json", "sarif":
+		return nil
+	default:
+		return fmt.Errorf("unknown diagnostics format %q, expected json or sarif", format)
+	}
+}
+
+// InDiagnosticsChild returns true if this process was started by RunWithDiagnostics.
+func InDiagnosticsChild() bool {
+	return os.Getenv(diagnosticsStreamEnv) != ""
+}
+
+// RunWithDiagnostics runs the current command again with the same arguments, and then writes the
+// diagnostics reported by the child process to file in the given format, either "json" or
+// "sarif".  It returns the exit code of the child.
+func RunWithDiagnostics(file, format string) (int, error) {
+	if err := checkDiagnosticsFormat(format); err != nil {
+		return 1, err
+	}
+
+	stream, err := ioutil.TempFile(filepath.Dir(file), ".diagnostics_stream")
+	if err != nil {
+		return 1, err
+	}
+	stream.Close()
+	defer os.Remove(stream.Name())
+
+	cmd := exec.Command(os.Args[0], os.Args[1:]...)
+	cmd.Env = append(os.Environ(), diagnosticsStreamEnv+"="+stream.Name())
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	exitCode := 0
+	if err := cmd.Run(); err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			return 1, err
+		}
+		exitCode = 1
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Exited() {
+			exitCode = status.ExitStatus()
+		}
+	}
+
+	diagnostics, err := readDiagnosticsStream(stream.Name())
+	if err != nil {
+		return 1, err
+	}
+
+	return exitCode, writeDiagnosticsFile(file, format, diagnostics)
+}
+
+// OpenDiagnosticsStream causes all diagnostics reported by modules to be appended to the stream
+// file passed by RunWithDiagnostics.  It does nothing if this process was not started by
+// RunWithDiagnostics.
+func (c *config) OpenDiagnosticsStream() error {
+	stream := os.Getenv(diagnosticsStreamEnv)
+	if stream == "" {
+		return nil
+	}
+
+	// The file is left open until the process exits, writes to it are not buffered
+	f, err := os.OpenFile(stream, os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+
+	c.diagnostics = &diagnosticsSink{w: f}
+	return nil
+}
+
+// diagnosticContext is the subset of the module and mutator contexts needed to report a
+// diagnostic.
+type diagnosticContext interface {
+	ModuleName() string
+	BlueprintsFile() string
+	Config() interface{}
+}
+
+// reportDiagnostic adds a diagnostic for the module in ctx to the diagnostics file, if one was
+// requested.
+func reportDiagnostic(ctx diagnosticContext, target Target, property, severity, message string) {
+	sink := ctx.Config().(Config).diagnostics
+	if sink == nil {
+		return
+	}
+
+	d := Diagnostic{
+		Module:   ctx.ModuleName(),
+		Variant:  diagnosticVariant(target),
+		Property: property,
+		File:     ctx.BlueprintsFile(),
+		Severity: severity,
+		Message:  message,
+	}
+
+	sink.add(d)
+}
+
+// diagnosticVariant returns the variant name used in diagnostics, which is the same for errors
+// reported from mutators and from GenerateAndroidBuildActions.  It is empty before the arch
+// mutator has run.
+func diagnosticVariant(target Target) string {
+	if target.Os == NoOsType {
+		return ""
+	}
+	return target.String()
+}
+
+func readDiagnosticsStream(stream string) ([]Diagnostic, error) {
+	f, err := os.Open(stream)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var diagnostics []Diagnostic
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		var d Diagnostic
+		if err := json.Unmarshal(scanner.Bytes(), &d); err != nil {
+			return nil, fmt.Errorf("%s: %s", stream, err.Error())
+		}
+		diagnostics = append(diagnostics, d)
+	}
+	return diagnostics, scanner.Err()
+}
+
+// writeDiagnosticsFile fills in the positions of the diagnostics and writes them to file.
+func writeDiagnosticsFile(file, format string, diagnostics []Diagnostic) error {
+	positions := blueprintPositions{make(map[string]*bpparser.File)}
+	for i := range diagnostics {
+		d := &diagnostics[i]
+		d.Line, d.Column = positions.position(d.File, d.Module, d.Property)
+	}
+
+	var v interface{}
+	if format == "sarif" {
+		v = sarifLog(diagnostics)
+	} else {
+		if diagnostics == nil {
+			diagnostics = []Diagnostic{}
+		}
+		v = diagnostics
+	}
+
+	data, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(file, append(data, '\n'), 0666)
+}
+
+// blueprintPositions finds modules and properties in Blueprints files, parsing each file once.
+type blueprintPositions struct {
+	files map[string]*bpparser.File
+}
+
+// position returns the line and column of a property of a module in a Blueprints file, or of the
+// module itself if the property is empty or can't be found.  It returns 0, 0 if the module can't
+// be found.
+func (s blueprintPositions) position(file, module, property string) (int, int) {
+	bp, ok := s.files[file]
+	if !ok {
+		bp = parseBlueprintsFileForDiagnostics(file)
+		s.files[file] = bp
+	}
+	if bp == nil {
+		return 0, 0
+	}
+
+	m := findBlueprintModule(bp, module)
+	if m == nil {
+		m = findBlueprintModule(bp, strings.TrimPrefix(module, "prebuilt_"))
+	}
+	if m == nil {
+		return 0, 0
+	}
+
+	props := m.Properties
+	var found *bpparser.Property
+	for _, name := range strings.Split(property, ".") {
+		found = nil
+		for _, p := range props {
+			if p.Name == name {
+				found = p
+				break
+			}
+		}
+		if found == nil {
+			break
+		}
+		if m, ok := found.Value.(*bpparser.Map); ok {
+			props = m.Properties
+		} else {
+			props = nil
+		}
+	}
+
+	if property != "" && found != nil {
+		return found.NamePos.Line, found.NamePos.Column
+	}
+	return m.TypePos.Line, m.TypePos.Column
+}
+
+func parseBlueprintsFileForDiagnostics(file string) *bpparser.File {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	bp, errs := bpparser.Parse(file, f, bpparser.NewScope(nil))
+	if len(errs) > 0 {
+		return nil
+	}
+	return bp
+}
+
+func findBlueprintModule(bp *bpparser.File, name string) *bpparser.Module {
+	for _, def := range bp.Defs {
+		m, ok := def.(*bpparser.Module)
+		if !ok {
+			continue
+		}
+		for _, p := range m.Properties {
+			if s, ok := p.Value.(*bpparser.String); ok && p.Name == "name" && s.Value == name {
+				return m
+			}
+		}
+	}
+	return nil
+}
+
+// The subset of the SARIF 2.1.0 format needed to describe diagnostics
+type sarif struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver struct {
+		Name string `json:"name"`
+	} `json:"driver"`
+}
+
+type sarifResult struct {
+	Level      string            `json:"level"`
+	Message    sarifMessage      `json:"message"`
+	Locations  []sarifLocation   `json:"locations"`
+	Properties map[string]string `json:"properties"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+		Region *sarifRegion `json:"region,omitempty"`
+	} `json:"physicalLocation"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+func sarifLog(diagnostics []Diagnostic) sarif {
+	run := sarifRun{
+		Results: []sarifResult{},
+	}
+	run.Tool.Driver.Name = "soong_build"
+
+	for _, d := range diagnostics {
+		var location sarifLocation
+		location.PhysicalLocation.ArtifactLocation.URI = d.File
+		if d.Line > 0 {
+			location.PhysicalLocation.Region = &sarifRegion{
+				StartLine:   d.Line,
+				StartColumn: d.Column,
+			}
+		}
+
+		properties := map[string]string{
+			"module": d.Module,
+		}
+		if d.Variant != "" {
+			properties["variant"] = d.Variant
+		}
+		if d.Property != "" {
+			properties["property"] = d.Property
+		}
+
+		run.Results = append(run.Results, sarifResult{
+			Level:      d.Severity,
+			Message:    sarifMessage{Text: d.Message},
+			Locations:  []sarifLocation{location},
+			Properties: properties,
+		})
+	}
+
+	return sarif{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{run},
+	}
+}
+
+func (a *androidModuleContext) ModuleErrorf(format string, args ...interface{}) {
+	reportDiagnostic(a, a.target, "", SeverityError, fmt.Sprintf(format, args...))
+	a.ModuleContext.ModuleErrorf(format, args...)
+}
+
+func (a *androidModuleContext) PropertyErrorf(property, format string, args ...interface{}) {
+	reportDiagnostic(a, a.target, property, SeverityError, fmt.Sprintf(format, args...))
+	a.ModuleContext.PropertyErrorf(property, format, args...)
+}
+
+func (a *androidBottomUpMutatorContext) ModuleErrorf(format string, args ...interface{}) {
+	reportDiagnostic(a, a.target, "", SeverityError, fmt.Sprintf(format, args...))
+	a.BottomUpMutatorContext.ModuleErrorf(format, args...)
+}
+
+func (a *androidBottomUpMutatorContext) PropertyErrorf(property, format string, args ...interface{}) {
+	reportDiagnostic(a, a.target, property, SeverityError, fmt.Sprintf(format, args...))
+	a.BottomUpMutatorContext.PropertyErrorf(property, format, args...)
+}
+
+func (a *androidTopDownMutatorContext) ModuleErrorf(format string, args ...interface{}) {
+	reportDiagnostic(a, a.target, "", SeverityError, fmt.Sprintf(format, args...))
+	a.TopDownMutatorContext.ModuleErrorf(format, args...)
+}
+
+func (a *androidTopDownMutatorContext) PropertyErrorf(property, format string, args ...interface{}) {
+	reportDiagnostic(a, a.target, property, SeverityError, fmt.Sprintf(format, args...))
+	a.TopDownMutatorContext.PropertyErrorf(property, format, args...)
+}
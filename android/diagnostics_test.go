@@ -0,0 +1,147 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	bpparser "github.com/google/blueprint/parser"
+)
+
+var diagnosticsBp = `
+cc_library {
+    name: "libfoo",
+    srcs: ["foo.c"],
+    arch: {
+        arm: {
+            cflags: ["-DARM"],
+        },
+    },
+}
+
+prebuilt_etc {
+    name: "bar",
+}
+`
+
+var diagnosticsPositionTestCases = []struct {
+	module   string
+	property string
+	line     int
+	column   int
+}{
+	{module: "libfoo", property: "", line: 2, column: 1},
+	{module: "libfoo", property: "srcs", line: 4, column: 5},
+	{module: "libfoo", property: "arch.arm.cflags", line: 7, column: 13},
+	{module: "libfoo", property: "shared_libs", line: 2, column: 1},
+	{module: "prebuilt_bar", property: "", line: 12, column: 1},
+	{module: "libbaz", property: "srcs", line: 0, column: 0},
+}
+
+func TestDiagnostics(t *testing.T) {
+	buildDir, err := ioutil.TempDir("", "soong_diagnostics_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(buildDir)
+
+	bp := filepath.Join(buildDir, "Android.bp")
+	if err := ioutil.WriteFile(bp, []byte(diagnosticsBp), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	positions := blueprintPositions{make(map[string]*bpparser.File)}
+	for _, test := range diagnosticsPositionTestCases {
+		line, column := positions.position(bp, test.module, test.property)
+		if line != test.line || column != test.column {
+			t.Errorf("%s %q: expected %d:%d, got %d:%d", test.module, test.property,
+				test.line, test.column, line, column)
+		}
+	}
+
+	stream := &bytes.Buffer{}
+	sink := &diagnosticsSink{w: stream}
+	sink.add(Diagnostic{
+		Module:   "libfoo",
+		Variant:  "android_arm64_armv8-a",
+		Property: "srcs",
+		File:     bp,
+		Severity: SeverityError,
+		Message:  "missing file",
+	})
+	sink.add(Diagnostic{
+		Module:   "libfoo",
+		File:     bp,
+		Severity: SeverityWarning,
+		Message:  "deprecated",
+	})
+
+	streamFile := filepath.Join(buildDir, "stream")
+	if err := ioutil.WriteFile(streamFile, stream.Bytes(), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	diagnostics, err := readDiagnosticsStream(streamFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diagnosticsFile := filepath.Join(buildDir, "diagnostics.json")
+	if err := writeDiagnosticsFile(diagnosticsFile, "json", diagnostics); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(diagnosticsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diagnostics = nil
+	if err := json.Unmarshal(data, &diagnostics); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(diagnostics) != 2 ||
+		diagnostics[0].Line != 4 || diagnostics[0].Message != "missing file" ||
+		diagnostics[0].Variant != "android_arm64_armv8-a" ||
+		diagnostics[1].Line != 2 || diagnostics[1].Severity != SeverityWarning {
+		t.Errorf("unexpected diagnostics %#v", diagnostics)
+	}
+
+	sarifFile := filepath.Join(buildDir, "diagnostics.sarif")
+	if err := writeDiagnosticsFile(sarifFile, "sarif", diagnostics); err != nil {
+		t.Fatal(err)
+	}
+	data, err = ioutil.ReadFile(sarifFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log sarif
+	if err := json.Unmarshal(data, &log); err != nil {
+		t.Fatal(err)
+	}
+	if len(log.Runs) != 1 || len(log.Runs[0].Results) != 2 {
+		t.Errorf("unexpected sarif log %s", data)
+	}
+
+	if _, err := RunWithDiagnostics(diagnosticsFile, "xml"); err == nil {
+		t.Errorf("expected error for unknown diagnostics format")
+	}
+}
@@ -15,6 +15,9 @@
 package android
 
 import (
+	"sort"
+	"strings"
+
 	"android/soong/env"
 
 	"github.com/google/blueprint"
@@ -25,7 +28,10 @@ import (
 // a JSON file is written containing the current value of all used environment variables.
 // The next time the top-level build script is run, it uses the soong_env executable to
 // compare the contents of the environment variables, rewriting the file if necessary to cause
-// a manifest regeneration.
+// a manifest regeneration.  A separate readers file records which modules read each variable,
+// which soong_env --explain uses to show what a change to a variable affects; the manifest does
+// not depend on it.  The EnvAllowlist and EnvDenylist product variables restrict which variables
+// may be read at all.
 
 func init() {
 	RegisterSingletonType("env", EnvSingleton)
@@ -38,7 +44,19 @@ func EnvSingleton() blueprint.Singleton {
 type envSingleton struct{}
 
 func (c *envSingleton) GenerateBuildActions(ctx blueprint.SingletonContext) {
-	envDeps := ctx.Config().(Config).EnvDeps()
+	config := ctx.Config().(Config)
+	envDeps := config.EnvDeps()
+
+	violations := config.EnvViolations()
+	var keys []string
+	for key := range violations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		ctx.Errorf("environment variable %q may not be read while generating the build manifest, read by %s",
+			key, strings.Join(violations[key], ", "))
+	}
 
 	envFile := PathForOutput(ctx, ".soong.environment")
 	if ctx.Failed() {
@@ -50,5 +68,10 @@ func (c *envSingleton) GenerateBuildActions(ctx blueprint.SingletonContext) {
 		ctx.Errorf(err.Error())
 	}
 
+	err = env.WriteEnvReadersFile(env.ReadersFile(envFile.String()), config.EnvReaders())
+	if err != nil {
+		ctx.Errorf(err.Error())
+	}
+
 	ctx.AddNinjaFileDeps(envFile.String())
 }
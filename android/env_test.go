@@ -0,0 +1,172 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"android/soong/env"
+)
+
+var getenvTestCases = []struct {
+	name       string
+	allowlist  []string
+	denylist   []string
+	value      string
+	deps       map[string]string
+	violations map[string][]string
+}{
+	{
+		name:       "no lists",
+		value:      "foo",
+		deps:       map[string]string{"SOONG_ENV_TEST": "foo"},
+		violations: map[string][]string{},
+	},
+	{
+		name:       "allowed",
+		allowlist:  []string{"SOONG_ENV_TEST"},
+		value:      "foo",
+		deps:       map[string]string{"SOONG_ENV_TEST": "foo"},
+		violations: map[string][]string{},
+	},
+	{
+		name:       "not in allowlist",
+		allowlist:  []string{"OTHER"},
+		deps:       map[string]string{},
+		violations: map[string][]string{"SOONG_ENV_TEST": {"//a:a", "//b:b"}},
+	},
+	{
+		name:       "empty allowlist",
+		allowlist:  []string{},
+		deps:       map[string]string{},
+		violations: map[string][]string{"SOONG_ENV_TEST": {"//a:a", "//b:b"}},
+	},
+	{
+		name:       "denied",
+		denylist:   []string{"SOONG_ENV_TEST"},
+		deps:       map[string]string{},
+		violations: map[string][]string{"SOONG_ENV_TEST": {"//a:a", "//b:b"}},
+	},
+}
+
+func TestGetenv(t *testing.T) {
+	oldValue, set := os.LookupEnv("SOONG_ENV_TEST")
+	defer func() {
+		if set {
+			os.Setenv("SOONG_ENV_TEST", oldValue)
+		} else {
+			os.Unsetenv("SOONG_ENV_TEST")
+		}
+	}()
+	os.Setenv("SOONG_ENV_TEST", "foo")
+
+	for _, test := range getenvTestCases {
+		config := TestConfig("out")
+		if test.allowlist != nil {
+			config.ProductVariables.EnvAllowlist = &test.allowlist
+		}
+		config.ProductVariables.EnvDenylist = test.denylist
+
+		for _, reader := range []string{"//b:b", "//a:a", "//b:b"} {
+			if value := config.getenv("SOONG_ENV_TEST", reader); value != test.value {
+				t.Errorf("%s: expected %q, got %q", test.name, test.value, value)
+			}
+		}
+
+		if deps := config.EnvDeps(); !reflect.DeepEqual(deps, test.deps) {
+			t.Errorf("%s: expected deps %q, got %q", test.name, test.deps, deps)
+		}
+		if violations := config.EnvViolations(); !reflect.DeepEqual(violations, test.violations) {
+			t.Errorf("%s: expected violations %q, got %q", test.name, test.violations, violations)
+		}
+		if len(test.violations) == 0 {
+			expected := []string{"//a:a", "//b:b"}
+			if readers := config.EnvReaders()["SOONG_ENV_TEST"]; !reflect.DeepEqual(readers, expected) {
+				t.Errorf("%s: expected readers %q, got %q", test.name, expected, readers)
+			}
+		}
+	}
+}
+
+func TestGetenvCallSite(t *testing.T) {
+	config := TestConfig("out")
+	config.Getenv("SOONG_ENV_TEST")
+
+	readers := config.EnvReaders()["SOONG_ENV_TEST"]
+	if len(readers) != 1 || !strings.Contains(readers[0], "TestGetenvCallSite (env_test.go:") {
+		t.Errorf("expected reader to be TestGetenvCallSite, got %q", readers)
+	}
+}
+
+func TestEnvFiles(t *testing.T) {
+	buildDir, err := ioutil.TempDir("", "soong_env_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(buildDir)
+
+	oldValue, set := os.LookupEnv("SOONG_ENV_TEST")
+	defer func() {
+		if set {
+			os.Setenv("SOONG_ENV_TEST", oldValue)
+		} else {
+			os.Unsetenv("SOONG_ENV_TEST")
+		}
+	}()
+	os.Setenv("SOONG_ENV_TEST", "foo")
+
+	config := TestConfig(buildDir)
+	config.getenv("SOONG_ENV_TEST", "//b:b")
+	config.getenv("SOONG_ENV_TEST", "//a:a")
+
+	ctx := NewContext()
+	ctx.MockFileSystem(map[string][]byte{
+		"Blueprints": []byte(""),
+	})
+	_, errs := ctx.ParseBlueprintsFiles("Blueprints")
+	fail(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+	fail(t, errs)
+
+	// The environment file only contains the values of the variables
+	envFile := filepath.Join(buildDir, ".soong.environment")
+	data, err := ioutil.ReadFile(envFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var contents []map[string]string
+	if err := json.Unmarshal(data, &contents); err != nil {
+		t.Fatal(err)
+	}
+	expected := []map[string]string{{"Key": "SOONG_ENV_TEST", "Value": "foo"}}
+	if !reflect.DeepEqual(contents, expected) {
+		t.Errorf("expected environment file %q, got %q", expected, contents)
+	}
+
+	readers, err := env.ReadEnvReadersFile(env.ReadersFile(envFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedReaders := map[string][]string{"SOONG_ENV_TEST": {"//a:a", "//b:b"}}
+	if !reflect.DeepEqual(readers, expectedReaders) {
+		t.Errorf("expected readers %q, got %q", expectedReaders, readers)
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/blueprint"
+)
+
+// This file implements the glob change log.  Every glob evaluated through
+// ModuleContext.GlobWithDeps, which includes ModuleContext.Glob, globs in srcs properties and
+// OverlayPath, makes the build manifest depend on the directories it walked, so adding or removing
+// a matching file re-runs Soong.  The globChangesSingleton writes the result of every glob, keyed
+// by pattern and excludes, to .glob_results.json in the build directory.  On the next run it
+// compares the new results with the saved ones, and appends the patterns whose results changed,
+// along with the files that appeared or disappeared, to glob_changes.log.
+//
+// The saved results are only used to explain a re-run, globs are always evaluated again.  Globs
+// passed to bootstrap.GlobFile are evaluated by ninja when the file list is built, and a change to
+// their result rebuilds the file list without re-running Soong, so they are not logged.
+
+func init() {
+	RegisterSingletonType("glob_changes", GlobChangesSingleton)
+}
+
+// globResult is the result of a glob, and the modules that evaluated it.
+type globResult struct {
+	Pattern  string
+	Excludes []string `json:",omitempty"`
+	Files    []string
+	Modules  []string `json:",omitempty"`
+}
+
+func (e globResult) key() string {
+	return e.Pattern + "\x00" + strings.Join(e.Excludes, "\x00")
+}
+
+func (e globResult) String() string {
+	if len(e.Excludes) > 0 {
+		return fmt.Sprintf("%s (excluding %s)", e.Pattern, strings.Join(e.Excludes, ", "))
+	}
+	return e.Pattern
+}
+
+type globResults []globResult
+
+func (s globResults) Len() int           { return len(s) }
+func (s globResults) Less(i, j int) bool { return s[i].key() < s[j].key() }
+func (s globResults) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// globChange is a glob whose result differs from the saved result.
+type globChange struct {
+	globResult
+	Added, Removed []string
+}
+
+// GlobWithDeps overrides blueprint.ModuleContext.GlobWithDeps to record the result of the glob
+// for the glob change log.
+func (a *androidModuleContext) GlobWithDeps(globPattern string, excludes []string) ([]string, error) {
+	files, err := a.ModuleContext.GlobWithDeps(globPattern, excludes)
+	if err == nil {
+		a.globs = append(a.globs, globResult{
+			Pattern:  globPattern,
+			Excludes: append([]string(nil), excludes...),
+			Files:    append([]string(nil), files...),
+		})
+	}
+	return files, err
+}
+
+// mergeGlobResults returns one sorted entry for each pattern and excludes pair, with the
+// names of all the modules that evaluated it.
+func mergeGlobResults(entries []globResult, modules []string) globResults {
+	byKey := make(map[string]int)
+	var ret globResults
+	for i, entry := range entries {
+		key := entry.key()
+		if j, ok := byKey[key]; ok {
+			if !inList(modules[i], ret[j].Modules) {
+				ret[j].Modules = append(ret[j].Modules, modules[i])
+			}
+			continue
+		}
+		entry.Files = append([]string(nil), entry.Files...)
+		sort.Strings(entry.Files)
+		entry.Modules = []string{modules[i]}
+		byKey[key] = len(ret)
+		ret = append(ret, entry)
+	}
+
+	for i := range ret {
+		sort.Strings(ret[i].Modules)
+	}
+	sort.Sort(ret)
+	return ret
+}
+
+// globChanges returns the entries in cur whose files differ from the entry with the same pattern
+// and excludes in old.  Entries that are not in old are new globs, not changed results, and are
+// not returned.
+func globChanges(old, cur globResults) []globChange {
+	oldByKey := make(map[string]globResult)
+	for _, entry := range old {
+		oldByKey[entry.key()] = entry
+	}
+
+	var ret []globChange
+	for _, entry := range cur {
+		oldEntry, ok := oldByKey[entry.key()]
+		if !ok {
+			continue
+		}
+		added := filesNotIn(entry.Files, oldEntry.Files)
+		removed := filesNotIn(oldEntry.Files, entry.Files)
+		if len(added) > 0 || len(removed) > 0 {
+			ret = append(ret, globChange{entry, added, removed})
+		}
+	}
+	return ret
+}
+
+func filesNotIn(files, other []string) []string {
+	set := make(map[string]bool, len(other))
+	for _, f := range other {
+		set[f] = true
+	}
+	var ret []string
+	for _, f := range files {
+		if !set[f] {
+			ret = append(ret, f)
+		}
+	}
+	return ret
+}
+
+func formatGlobChanges(now time.Time, changes []globChange) string {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "%s: %d glob results changed\n", now.Format("2006-01-02 15:04:05"), len(changes))
+	for _, change := range changes {
+		fmt.Fprintf(buf, "  %s\n", change.globResult)
+		fmt.Fprintf(buf, "    used by %s\n", strings.Join(change.Modules, ", "))
+		for _, f := range change.Added {
+			fmt.Fprintf(buf, "    + %s\n", f)
+		}
+		for _, f := range change.Removed {
+			fmt.Fprintf(buf, "    - %s\n", f)
+		}
+	}
+	return buf.String()
+}
+
+func GlobChangesSingleton() blueprint.Singleton {
+	return &globChangesSingleton{}
+}
+
+type globChangesSingleton struct{}
+
+func (c *globChangesSingleton) GenerateBuildActions(ctx blueprint.SingletonContext) {
+	var entries []globResult
+	var modules []string
+
+	ctx.VisitAllModules(func(module blueprint.Module) {
+		a, ok := module.(Module)
+		if !ok {
+			return
+		}
+
+		for _, entry := range a.base().globs {
+			entries = append(entries, entry)
+			modules = append(modules, ctx.ModuleName(module))
+		}
+	})
+
+	cur := mergeGlobResults(entries, modules)
+	if cur == nil {
+		cur = globResults{}
+	}
+
+	resultsFile := PathForOutput(ctx, ".glob_results.json")
+	logFile := PathForOutput(ctx, "glob_changes.log")
+	if ctx.Failed() {
+		return
+	}
+
+	oldData, err := ioutil.ReadFile(resultsFile.String())
+	if err == nil {
+		var old globResults
+		// Corrupt saved results are treated like missing ones
+		if json.Unmarshal(oldData, &old) == nil {
+			if changes := globChanges(old, cur); len(changes) > 0 {
+				err := appendFile(logFile.String(), formatGlobChanges(time.Now(), changes))
+				if err != nil {
+					ctx.Errorf("%s", err)
+					return
+				}
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		ctx.Errorf("%s", err)
+		return
+	}
+
+	data, err := json.MarshalIndent(cur, "", "    ")
+	if err != nil {
+		ctx.Errorf("%s", err)
+		return
+	}
+	data = append(data, '\n')
+
+	if !bytes.Equal(oldData, data) {
+		if err := ioutil.WriteFile(resultsFile.String(), data, 0666); err != nil {
+			ctx.Errorf("%s", err)
+		}
+	}
+}
+
+func appendFile(filename, s string) error {
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(s)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
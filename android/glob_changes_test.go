@@ -0,0 +1,94 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMergeGlobResults(t *testing.T) {
+	entries := []globResult{
+		{Pattern: "b/**/*", Files: []string{"b/2", "b/1"}},
+		{Pattern: "a/*", Excludes: []string{"a/x"}, Files: []string{"a/1"}},
+		{Pattern: "b/**/*", Files: []string{"b/1", "b/2"}},
+		{Pattern: "a/*", Files: []string{"a/1", "a/x"}},
+		{Pattern: "b/**/*", Files: []string{"b/1", "b/2"}},
+	}
+	modules := []string{"foo", "bar", "baz", "bar", "foo"}
+
+	expected := globResults{
+		{Pattern: "a/*", Files: []string{"a/1", "a/x"}, Modules: []string{"bar"}},
+		{Pattern: "a/*", Excludes: []string{"a/x"}, Files: []string{"a/1"}, Modules: []string{"bar"}},
+		{Pattern: "b/**/*", Files: []string{"b/1", "b/2"}, Modules: []string{"baz", "foo"}},
+	}
+
+	if got := mergeGlobResults(entries, modules); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected\n%q\ngot\n%q", expected, got)
+	}
+}
+
+var globChangesTestCases = []struct {
+	name     string
+	old, cur globResults
+	expected string
+}{
+	{
+		name: "unchanged",
+		old:  globResults{{Pattern: "a/*", Files: []string{"a/1"}}},
+		cur:  globResults{{Pattern: "a/*", Files: []string{"a/1"}}},
+	},
+	{
+		name: "new glob",
+		cur:  globResults{{Pattern: "a/*", Files: []string{"a/1"}}},
+	},
+	{
+		name: "different excludes",
+		old:  globResults{{Pattern: "a/*", Files: []string{"a/1"}}},
+		cur:  globResults{{Pattern: "a/*", Excludes: []string{"a/1"}}},
+	},
+	{
+		name: "added and removed",
+		old: globResults{
+			{Pattern: "a/*", Files: []string{"a/1"}},
+			{Pattern: "res/**/*", Excludes: []string{"**/.*"}, Files: []string{"res/a", "res/b"}},
+		},
+		cur: globResults{
+			{Pattern: "a/*", Files: []string{"a/1"}},
+			{Pattern: "res/**/*", Excludes: []string{"**/.*"}, Files: []string{"res/b", "res/c"},
+				Modules: []string{"app"}},
+		},
+		expected: "2017-06-01 12:00:00: 1 glob results changed\n" +
+			"  res/**/* (excluding **/.*)\n" +
+			"    used by app\n" +
+			"    + res/c\n" +
+			"    - res/a\n",
+	},
+}
+
+func TestGlobChanges(t *testing.T) {
+	now := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, test := range globChangesTestCases {
+		changes := globChanges(test.old, test.cur)
+		var got string
+		if len(changes) > 0 {
+			got = formatGlobChanges(now, changes)
+		}
+		if got != test.expected {
+			t.Errorf("%s: expected\n%s\ngot\n%s", test.name, test.expected, got)
+		}
+	}
+}
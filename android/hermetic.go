@@ -0,0 +1,283 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/google/blueprint"
+)
+
+// This file implements the optional hermeticity checks enabled with SOONG_HERMETIC_CHECKS=true.
+// When enabled, every input of a build statement created with ModuleBuild must be either:
+//   a source file in the module's directory
+//   a file in an include directory exported by one of the module's direct dependencies
+//   an output file of one of the module's direct dependencies, either one of the files returned
+//     by OutputFiles or a file in the dependency's .intermediates directory
+//   an output file outside of .intermediates, or in the module's own .intermediates directory
+//   a path containing a ninja variable, such as the toolchain binaries, which are defined by the
+//     build itself
+// The arguments of the build statement, and commands passed to CheckHermeticCommand, must not
+// refer to source files through absolute paths or paths containing ../ unless the file is also
+// a declared input.
+
+// ExportedIncludeDirsProducer is implemented by modules that export include directories to the
+// modules that depend on them.  The hermeticity checks allow the modules that depend on them to
+// read any file in the exported directories.
+type ExportedIncludeDirsProducer interface {
+	ExportedIncludeDirs() []string
+}
+
+type hermeticChecker struct {
+	srcDir      string
+	absSrcDir   string
+	buildDir    string
+	absBuildDir string
+
+	// Source directories the module may read from
+	srcDirs []string
+	// Include directories exported by the module's dependencies, which may be source or output
+	// directories
+	includeDirs []string
+	// Directories under buildDir/.intermediates the module may read from
+	outDirs []string
+	// Output files of the module's dependencies
+	depOutputs map[string]bool
+}
+
+func newHermeticChecker(srcDir, absSrcDir, buildDir, absBuildDir string) *hermeticChecker {
+	return &hermeticChecker{
+		srcDir:      filepath.Clean(srcDir),
+		absSrcDir:   filepath.Clean(absSrcDir),
+		buildDir:    filepath.Clean(buildDir),
+		absBuildDir: filepath.Clean(absBuildDir),
+		depOutputs:  make(map[string]bool),
+	}
+}
+
+// allowModule allows the checked module to use the sources and intermediates of its own directory.
+func (h *hermeticChecker) allowModule(dir, name string) {
+	h.srcDirs = append(h.srcDirs, dir)
+	h.outDirs = append(h.outDirs, filepath.Join(h.buildDir, ".intermediates", dir, name))
+}
+
+// allowDependency allows the checked module to use the exported include directories and the
+// outputs of a direct dependency.
+func (h *hermeticChecker) allowDependency(dir, name string, includeDirs, outputs []string) {
+	h.outDirs = append(h.outDirs, filepath.Join(h.buildDir, ".intermediates", dir, name))
+	for _, includeDir := range includeDirs {
+		h.includeDirs = append(h.includeDirs, filepath.Clean(includeDir))
+	}
+	for _, output := range outputs {
+		h.depOutputs[filepath.Clean(output)] = true
+	}
+}
+
+func inDir(path, dir string) bool {
+	return dir == "." || path == dir || strings.HasPrefix(path, dir+"/")
+}
+
+// checkInput returns an error if the module may not use path as an input.
+func (h *hermeticChecker) checkInput(path string) error {
+	if strings.Contains(path, "$") {
+		return nil
+	}
+
+	path = filepath.Clean(path)
+
+	if h.depOutputs[path] {
+		return nil
+	}
+	for _, dir := range h.includeDirs {
+		if inDir(path, dir) {
+			return nil
+		}
+	}
+
+	if inDir(path, h.buildDir) {
+		if !inDir(path, filepath.Join(h.buildDir, ".intermediates")) {
+			return nil
+		}
+		for _, dir := range h.outDirs {
+			if inDir(path, dir) {
+				return nil
+			}
+		}
+		return fmt.Errorf("input %q is an output of a module that is not a dependency", path)
+	}
+
+	rel, err := filepath.Rel(h.srcDir, path)
+	if err != nil || strings.HasPrefix(rel, "../") || rel == ".." {
+		return fmt.Errorf("input %q is outside the source tree", path)
+	}
+	for _, dir := range h.srcDirs {
+		if inDir(rel, dir) {
+			return nil
+		}
+	}
+	return fmt.Errorf("input %q is not in the module's directory, an include directory exported "+
+		"by a dependency, or an output of a dependency", path)
+}
+
+// Flags that may be directly followed by a path
+var hermeticPathFlags = []string{"-B", "-F", "-I", "-L", "-idirafter", "-include", "-iquote", "-isystem"}
+
+// commandPaths returns the words in a command or argument that look like absolute paths or paths
+// containing .., with any leading flag in hermeticPathFlags removed.
+func commandPaths(cmd string) []string {
+	words := strings.FieldsFunc(cmd, func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune(`'"=,:;()`, r)
+	})
+
+	var ret []string
+	for _, word := range words {
+		if strings.HasPrefix(word, "-") {
+			flag := ""
+			for _, f := range hermeticPathFlags {
+				if strings.HasPrefix(word, f) && len(f) > len(flag) {
+					flag = f
+				}
+			}
+			if flag == "" {
+				continue
+			}
+			word = strings.TrimPrefix(word, flag)
+		}
+		if strings.HasPrefix(word, "/") || word == ".." || strings.HasPrefix(word, "../") ||
+			strings.Contains(word, "/../") || strings.HasSuffix(word, "/..") {
+			ret = append(ret, word)
+		}
+	}
+	return ret
+}
+
+// checkCommand returns errors for the absolute or .. paths in cmd that refer to source files that
+// are not in inputs.
+func (h *hermeticChecker) checkCommand(cmd string, inputs []string) []error {
+	declared := make(map[string]bool)
+	for _, input := range inputs {
+		declared[filepath.Clean(input)] = true
+	}
+
+	var errs []error
+	for _, path := range commandPaths(cmd) {
+		var rel string
+		if filepath.IsAbs(path) {
+			clean := filepath.Clean(path)
+			if inDir(clean, h.absBuildDir) {
+				// An output file, the build directory may be inside the source directory
+				continue
+			}
+			if !inDir(clean, h.absSrcDir) {
+				// Not a source file, for example /bin/bash or /dev/null
+				continue
+			}
+			rel, _ = filepath.Rel(h.absSrcDir, clean)
+		} else {
+			rel = filepath.Clean(path)
+			if rel == ".." || strings.HasPrefix(rel, "../") {
+				errs = append(errs, fmt.Errorf("%q refers to a path outside the source tree", path))
+				continue
+			}
+			if inDir(filepath.Join(h.srcDir, rel), h.buildDir) {
+				continue
+			}
+		}
+
+		if !declared[filepath.Join(h.srcDir, rel)] {
+			errs = append(errs, fmt.Errorf("%q refers to source file %q, which is not a declared input",
+				path, filepath.Join(h.srcDir, rel)))
+		}
+	}
+	return errs
+}
+
+// moduleHermeticChecker returns a hermeticChecker for the module in ctx.
+func moduleHermeticChecker(ctx ModuleContext) *hermeticChecker {
+	config := ctx.AConfig()
+	absSrcDir, _ := filepath.Abs(config.srcDir)
+	absBuildDir, _ := filepath.Abs(config.buildDir)
+	h := newHermeticChecker(config.srcDir, absSrcDir, config.buildDir, absBuildDir)
+	h.allowModule(ctx.ModuleDir(), ctx.ModuleName())
+	ctx.VisitDirectDeps(func(dep blueprint.Module) {
+		d, ok := dep.(Module)
+		if !ok {
+			return
+		}
+
+		var includeDirs, outputs []string
+		if producer, ok := dep.(ExportedIncludeDirsProducer); ok {
+			includeDirs = producer.ExportedIncludeDirs()
+		}
+		if producer, ok := dep.(OutputFileProducer); ok {
+			// Modules without default output files return an error, which means there are none
+			if files, err := producer.OutputFiles(""); err == nil {
+				outputs = files.Strings()
+			}
+		}
+		h.allowDependency(d.base().commonProperties.ModuleDir, ctx.OtherModuleName(dep),
+			includeDirs, outputs)
+	})
+	return h
+}
+
+// checkHermetic reports errors for inputs and arguments of a build statement that break the
+// hermeticity rules.
+func (a *androidModuleContext) checkHermetic(params blueprint.BuildParams) {
+	if a.hermetic == nil {
+		a.hermetic = moduleHermeticChecker(a)
+	}
+	h := a.hermetic
+
+	var inputs []string
+	inputs = append(inputs, params.Inputs...)
+	inputs = append(inputs, params.Implicits...)
+	inputs = append(inputs, params.OrderOnly...)
+
+	for _, input := range inputs {
+		if err := h.checkInput(input); err != nil {
+			a.ModuleErrorf("hermetic: %s", err.Error())
+		}
+	}
+
+	var argNames []string
+	for name := range params.Args {
+		argNames = append(argNames, name)
+	}
+	sort.Strings(argNames)
+
+	for _, name := range argNames {
+		for _, err := range h.checkCommand(params.Args[name], inputs) {
+			a.ModuleErrorf("hermetic: %s: %s", name, err.Error())
+		}
+	}
+}
+
+// CheckHermeticCommand reports errors for absolute or .. paths in a command that refer to source
+// files that are not in inputs, if the hermeticity checks are enabled.  It is used by module types
+// that create rules with commands from user-supplied properties.
+func CheckHermeticCommand(ctx ModuleContext, property, cmd string, inputs Paths) {
+	if !ctx.AConfig().HermeticChecks() {
+		return
+	}
+
+	for _, err := range moduleHermeticChecker(ctx).checkCommand(cmd, inputs.Strings()) {
+		ctx.PropertyErrorf(property, "hermetic: %s", err.Error())
+	}
+}
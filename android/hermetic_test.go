@@ -0,0 +1,134 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testHermeticChecker() *hermeticChecker {
+	h := newHermeticChecker(".", "/src", "out/soong", "/src/out/soong")
+	h.allowModule("frameworks/foo", "libfoo")
+	h.allowDependency("external/bar", "libbar",
+		[]string{"external/bar/include", "out/soong/.intermediates/external/gen/gen"},
+		[]string{"prebuilts/bar/libbar.a"})
+	return h
+}
+
+func TestHermeticCheckInput(t *testing.T) {
+	testCases := []struct {
+		input string
+		err   bool
+	}{
+		{input: "frameworks/foo/foo.c"},
+		{input: "frameworks/foo/sub/foo.h"},
+		{input: "external/bar/include/bar.h"},
+		{input: "external/bar/include/sub/../bar.h"},
+		{input: "out/soong/.intermediates/external/gen/gen/gen.h"},
+		{input: "prebuilts/bar/libbar.a"},
+		{input: "${config.ClangBin}/clang"},
+		{input: "out/soong/.intermediates/frameworks/foo/libfoo/android_arm_shared/foo.o"},
+		{input: "out/soong/.intermediates/external/bar/libbar/android_arm_static/libbar.a"},
+		{input: "out/soong/host/linux-x86/bin/aidl"},
+
+		// Errors
+		{input: "external/bar/bar.c", err: true},
+		{input: "external/bar/include/../bar.h", err: true},
+		{input: "prebuilts/bar/libbar.so", err: true},
+		{input: "prebuilts/clang/bin/clang", err: true},
+		{input: "build/soong/scripts/strip.sh", err: true},
+		{input: "out/soong/.intermediates/external/gen/gen.h", err: true},
+		{input: "frameworks/foobar/foo.c", err: true},
+		{input: "external/baz/baz.c", err: true},
+		{input: "out/soong/.intermediates/external/baz/libbaz/android_arm_static/libbaz.a", err: true},
+		{input: "../outside/foo.c", err: true},
+	}
+
+	h := testHermeticChecker()
+	for _, test := range testCases {
+		err := h.checkInput(test.input)
+		if err != nil && !test.err {
+			t.Errorf("%q: unexpected error %s", test.input, err.Error())
+		} else if err == nil && test.err {
+			t.Errorf("%q: expected error", test.input)
+		}
+	}
+}
+
+func TestCommandPaths(t *testing.T) {
+	testCases := []struct {
+		cmd string
+		out []string
+	}{
+		{
+			cmd: "-Iframeworks/foo/include -O2 -DFOO=1",
+			out: nil,
+		},
+		{
+			cmd: "-I../include -Wl,--version-script,frameworks/foo/../bar/foo.map /bin/bash",
+			out: []string{"../include", "frameworks/foo/../bar/foo.map", "/bin/bash"},
+		},
+		{
+			cmd: `cat "/src/external/baz/baz.txt" > $out`,
+			out: []string{"/src/external/baz/baz.txt"},
+		},
+	}
+
+	for _, test := range testCases {
+		got := commandPaths(test.cmd)
+		if !reflect.DeepEqual(got, test.out) {
+			t.Errorf("%q: expected %q, got %q", test.cmd, test.out, got)
+		}
+	}
+}
+
+func TestHermeticCheckCommand(t *testing.T) {
+	testCases := []struct {
+		cmd    string
+		inputs []string
+		errs   int
+	}{
+		{
+			cmd:    "$tool /bin/bash /dev/null -Iframeworks/foo/include",
+			inputs: nil,
+		},
+		{
+			cmd:    "$tool -c /src/frameworks/foo/foo.cfg frameworks/foo/sub/../foo.txt",
+			inputs: []string{"frameworks/foo/foo.cfg", "frameworks/foo/foo.txt"},
+		},
+		{
+			cmd:    "$tool -c /src/frameworks/foo/foo.cfg frameworks/foo/sub/../foo.txt",
+			inputs: []string{"frameworks/foo/foo.cfg"},
+			errs:   1,
+		},
+		{
+			cmd:  "$tool ../../outside.txt -I../include",
+			errs: 2,
+		},
+		{
+			cmd:    "$tool /src/out/soong/.intermediates/frameworks/foo/libfoo/gen/foo.h out/soong/foo.txt",
+			inputs: nil,
+		},
+	}
+
+	h := testHermeticChecker()
+	for _, test := range testCases {
+		errs := h.checkCommand(test.cmd, test.inputs)
+		if len(errs) != test.errs {
+			t.Errorf("%q: expected %d errors, got %q", test.cmd, test.errs, errs)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+
+	"github.com/google/blueprint"
+)
+
+// This file implements the installed files manifest.  Every file installed through InstallFile,
+// InstallFileName or InstallSymlink is recorded, and the installedFilesSingleton writes the sorted
+// list to installed-files-list.json and reports an error if two modules install the same path.
+// The sizes of the installed files are only known after they have been built, so a build rule
+// runs the installed_files tool to produce installed-files.json from the list, with the size of
+// every file added.
+
+func init() {
+	RegisterSingletonType("installed_files", InstalledFilesSingleton)
+
+	pctx.StaticVariable("installedFilesCmd", filepath.Join("${bootstrap.ToolDir}", "installed_files"))
+}
+
+var installedFilesRule = pctx.AndroidStaticRule("installedFiles",
+	blueprint.RuleParams{
+		Command:     "${installedFilesCmd} -o ${out} ${in}",
+		CommandDeps: []string{"${installedFilesCmd}"},
+		Description: "installed files list ${out}",
+	})
+
+// installEntry is a file installed by a module variant, recorded by InstallFileName and
+// InstallSymlink.  Entries are recorded for device files in builds that are embedded in Make too,
+// where Make installs them instead of Soong.
+type installEntry struct {
+	path OutputPath
+	// The file that was copied to path, or the target of the symlink
+	src     Path
+	symlink bool
+	// Make installs the file, so there is no build statement in Soong's manifest that creates it
+	installedByMake bool
+}
+
+// installPaths returns the paths of the files installed by a module variant, including the files
+// that Make installs.
+func (a *ModuleBase) installPaths() []string {
+	var paths []string
+	for _, entry := range a.installEntries {
+		paths = append(paths, entry.path.String())
+	}
+	return paths
+}
+
+// installedFile is an entry in installed-files-list.json.  It must match the struct of the same
+// name in cmd/installed_files.
+type installedFile struct {
+	Path    string
+	Module  string
+	Variant string `json:",omitempty"`
+	Source  string `json:",omitempty"`
+	Symlink string `json:",omitempty"`
+}
+
+type installedFiles []installedFile
+
+func (s installedFiles) Len() int { return len(s) }
+func (s installedFiles) Less(i, j int) bool {
+	if s[i].Path != s[j].Path {
+		return s[i].Path < s[j].Path
+	}
+	if s[i].Module != s[j].Module {
+		return s[i].Module < s[j].Module
+	}
+	return s[i].Variant < s[j].Variant
+}
+func (s installedFiles) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+// installConflicts returns pairs of entries in a sorted list of installed files that install the
+// same path from different module variants.
+func installConflicts(files installedFiles) [][2]installedFile {
+	var conflicts [][2]installedFile
+	for i := 1; i < len(files); i++ {
+		if files[i].Path == files[i-1].Path {
+			conflicts = append(conflicts, [2]installedFile{files[i-1], files[i]})
+		}
+	}
+	return conflicts
+}
+
+func InstalledFilesSingleton() blueprint.Singleton {
+	return &installedFilesSingleton{}
+}
+
+type installedFilesSingleton struct{}
+
+func (c *installedFilesSingleton) GenerateBuildActions(ctx blueprint.SingletonContext) {
+	var files installedFiles
+	var installs []string
+
+	ctx.VisitAllModules(func(module blueprint.Module) {
+		a, ok := module.(Module)
+		if !ok {
+			return
+		}
+
+		for _, entry := range a.base().installEntries {
+			file := installedFile{
+				Path:    entry.path.String(),
+				Module:  ctx.ModuleName(module),
+				Variant: ctx.ModuleSubDir(module),
+			}
+			if entry.symlink {
+				file.Symlink = entry.src.String()
+			} else {
+				file.Source = entry.src.String()
+			}
+			files = append(files, file)
+			if !entry.installedByMake {
+				installs = append(installs, file.Path)
+			}
+		}
+	})
+
+	sort.Sort(files)
+
+	for _, conflict := range installConflicts(files) {
+		ctx.Errorf("%q is installed by both module %q variant %q and module %q variant %q",
+			conflict[0].Path, conflict[0].Module, conflict[0].Variant,
+			conflict[1].Module, conflict[1].Variant)
+	}
+
+	if files == nil {
+		files = installedFiles{}
+	}
+
+	data, err := json.MarshalIndent(files, "", "    ")
+	if err != nil {
+		ctx.Errorf("%s", err)
+		return
+	}
+	data = append(data, '\n')
+
+	listFile := PathForOutput(ctx, "installed-files-list.json")
+	outFile := PathForOutput(ctx, "installed-files.json")
+	if ctx.Failed() {
+		return
+	}
+
+	if old, err := ioutil.ReadFile(listFile.String()); err != nil || !bytes.Equal(old, data) {
+		if err := ioutil.WriteFile(listFile.String(), data, 0666); err != nil {
+			ctx.Errorf("%s", err)
+			return
+		}
+	}
+
+	ctx.Build(pctx, blueprint.BuildParams{
+		Rule:      installedFilesRule,
+		Outputs:   []string{outFile.String()},
+		Inputs:    []string{listFile.String()},
+		Implicits: installs,
+		Optional:  ctx.Config().(Config).EmbeddedInMake(),
+	})
+
+	ctx.Build(pctx, blueprint.BuildParams{
+		Rule:      blueprint.Phony,
+		Outputs:   []string{"installed-files"},
+		Implicits: []string{outFile.String()},
+		Optional:  true,
+	})
+}
@@ -0,0 +1,184 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/blueprint"
+)
+
+func TestInstallConflicts(t *testing.T) {
+	files := installedFiles{
+		{Path: "system/lib/libfoo.so", Module: "libfoo", Variant: "android_arm_shared"},
+		{Path: "system/bin/foo", Module: "foo", Variant: "android_arm64"},
+		{Path: "system/lib/libfoo.so", Module: "libfoo_prebuilt", Variant: "android_arm_shared"},
+		{Path: "system/lib64/libfoo.so", Module: "libfoo", Variant: "android_arm64_shared"},
+		{Path: "system/bin/sh", Module: "mksh", Symlink: "/system/bin/mksh"},
+	}
+
+	sort.Sort(files)
+
+	expectedOrder := []string{
+		"system/bin/foo",
+		"system/bin/sh",
+		"system/lib/libfoo.so",
+		"system/lib/libfoo.so",
+		"system/lib64/libfoo.so",
+	}
+	var order []string
+	for _, f := range files {
+		order = append(order, f.Path)
+	}
+	if !reflect.DeepEqual(order, expectedOrder) {
+		t.Errorf("expected order %q, got %q", expectedOrder, order)
+	}
+
+	conflicts := installConflicts(files)
+	if len(conflicts) != 1 {
+		t.Fatalf("expected 1 conflict, got %d: %v", len(conflicts), conflicts)
+	}
+	if conflicts[0][0].Module != "libfoo" || conflicts[0][1].Module != "libfoo_prebuilt" {
+		t.Errorf("unexpected conflict %v", conflicts[0])
+	}
+}
+
+func TestInstalledFilesSkipDeviceInstall(t *testing.T) {
+	buildDir, err := ioutil.TempDir("", "soong_installed_files_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(buildDir)
+
+	config := TestConfig(buildDir)
+	config.inMake = true
+	if !config.SkipDeviceInstall() {
+		t.Fatal("expected SkipDeviceInstall")
+	}
+
+	ctx := NewContext()
+	ctx.RegisterModuleType("install", newInstallModule)
+	ctx.MockFileSystem(map[string][]byte{
+		"Blueprints": []byte(`
+			install {
+				name: "foo",
+				installs: ["system/bin/foo"],
+			}
+
+			install {
+				name: "bar",
+				installs: ["system/bin/foo", "system/bin/bar"],
+			}
+		`),
+	})
+
+	_, errs := ctx.ParseBlueprintsFiles("Blueprints")
+	fail(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 conflict error, got %q", errs)
+	}
+	expectedErr := `"` + filepath.Join(buildDir, "system/bin/foo") + `" is installed by both module "bar"`
+	if !strings.Contains(errs[0].Error(), expectedErr) {
+		t.Errorf("expected error containing %q, got %q", expectedErr, errs[0])
+	}
+
+	foo := findModule(ctx, "foo").(*installModule)
+	if len(foo.installFiles) != 0 {
+		t.Errorf("expected no files installed by Soong, got %q", foo.installFiles)
+	}
+	if len(foo.installEntries) != 1 || !foo.installEntries[0].installedByMake {
+		t.Errorf("expected 1 install entry installed by Make, got %v", foo.installEntries)
+	}
+}
+
+func TestInstalledFilesList(t *testing.T) {
+	buildDir, err := ioutil.TempDir("", "soong_installed_files_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(buildDir)
+
+	for _, inMake := range []bool{false, true} {
+		config := TestConfig(buildDir)
+		config.inMake = inMake
+
+		ctx := NewContext()
+		ctx.RegisterModuleType("install", newInstallModule)
+		ctx.MockFileSystem(map[string][]byte{
+			"Blueprints": []byte(`
+				install {
+					name: "foo",
+					installs: ["system/bin/foo"],
+				}
+			`),
+		})
+
+		_, errs := ctx.ParseBlueprintsFiles("Blueprints")
+		fail(t, errs)
+		_, errs = ctx.PrepareBuildActions(config)
+		fail(t, errs)
+
+		data, err := ioutil.ReadFile(filepath.Join(buildDir, "installed-files-list.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var files installedFiles
+		if err := json.Unmarshal(data, &files); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := installedFiles{
+			{
+				Path:   filepath.Join(buildDir, "system/bin/foo"),
+				Module: "foo",
+				Source: filepath.Join(buildDir, "src/foo"),
+			},
+		}
+		if !reflect.DeepEqual(files, expected) {
+			t.Errorf("inMake %v: expected\n%#v\ngot\n%#v", inMake, expected, files)
+		}
+	}
+}
+
+// installModule installs each path in installs from a file with the same base name in src.
+type installModule struct {
+	ModuleBase
+	properties struct {
+		Installs []string
+	}
+}
+
+func newInstallModule() (blueprint.Module, []interface{}) {
+	m := &installModule{}
+	return InitAndroidModule(m, &m.properties)
+}
+
+func (m *installModule) DepsMutator(ctx BottomUpMutatorContext) {
+}
+
+func (m *installModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	for _, install := range m.properties.Installs {
+		name := filepath.Base(install)
+		ctx.InstallFileName(PathForOutput(ctx, filepath.Dir(install)), name, PathForOutput(ctx, "src", name))
+	}
+}
@@ -15,8 +15,12 @@
 package android
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/google/blueprint"
@@ -61,11 +65,17 @@ type androidBaseContext interface {
 	PrimaryArch() bool
 	AConfig() Config
 	DeviceConfig() DeviceConfig
+
+	// Getenv returns the value of an environment variable and records that this module read it.
+	Getenv(key string) string
 }
 
 type BaseContext interface {
 	blueprint.BaseModuleContext
 	androidBaseContext
+
+	ModuleWarningf(format string, args ...interface{})
+	PropertyWarningf(property, format string, args ...interface{})
 }
 
 type ModuleContext interface {
@@ -86,6 +96,9 @@ type ModuleContext interface {
 
 	AddMissingDependencies(deps []string)
 
+	ModuleWarningf(format string, args ...interface{})
+	PropertyWarningf(property, format string, args ...interface{})
+
 	Proprietary() bool
 	InstallInData() bool
 }
@@ -131,6 +144,10 @@ type commonProperties struct {
 
 	Default_multilib string `blueprint:"mutated"`
 
+	// whether this module is also built for the native bridge targets of the device, for example
+	// arm libraries on an x86_64 device
+	Native_bridge_supported *bool
+
 	// whether this is a proprietary vendor module, and should be installed into /vendor
 	Proprietary bool
 
@@ -144,6 +161,21 @@ type commonProperties struct {
 	// names of other modules to install if this module is installed
 	Required []string
 
+	// list of Blueprint directories whose modules may depend on this module, in the form
+	// "//path/to/dir:__pkg__", "//path/to/dir:__subpackages__", "//path/to/dir/...",
+	// ":__subpackages__", "//visibility:private" or "//visibility:public".  Defaults to
+	// "//visibility:public".  Modules in the same directory can always depend on this module.
+	Visibility []string
+
+	// email address of the person or list responsible for this module
+	Owner *string
+
+	// name of the team responsible for this module
+	Team *string
+
+	// bug tracker component for bugs in this module
+	Bug_component *string
+
 	// Set by TargetMutator
 	CompileTarget  Target `blueprint:"mutated"`
 	CompilePrimary bool   `blueprint:"mutated"`
@@ -153,6 +185,17 @@ type commonProperties struct {
 	ArchSpecific          bool                  `blueprint:"mutated"`
 
 	SkipInstall bool `blueprint:"mutated"`
+
+	// Set by visibilityMutator
+	ModuleDir string `blueprint:"mutated"`
+
+	// Set by CreateVariations and CreateLocalVariations in the mutators that run before the deps
+	// mutator, to the mutators other than arch that split the module into more than one variant
+	SplitMutators []string `blueprint:"mutated"`
+
+	// Set by CreateVariations and CreateLocalVariations on every variant other than the first one
+	// created by each mutator, so errors that are the same for all variants are reported once
+	NotFirstVariant bool `blueprint:"mutated"`
 }
 
 type hostAndDeviceProperties struct {
@@ -192,6 +235,8 @@ func InitAndroidModule(m Module,
 		&base.commonProperties,
 		&base.variableProperties)
 
+	base.propertyStructs = propertyStructs
+
 	return m, propertyStructs
 }
 
@@ -270,10 +315,18 @@ type ModuleBase struct {
 	generalProperties       []interface{}
 	archProperties          []interface{}
 	customizableProperties  []interface{}
+	// All property structs passed to InitAndroidModule, used by deprecatedPropertiesMutator for
+	// module types that don't call InitArchModule
+	propertyStructs []interface{}
+
+	// Added by customVariablesFactory, nil if the product has no custom variables
+	customVariableProperties interface{}
 
 	noAddressSanitizer bool
 	installFiles       Paths
 	checkbuildFiles    Paths
+	installEntries     []installEntry
+	globs              []globResult
 
 	// Used by buildTargetSingleton to create checkbuild and per-directory build targets
 	// Only set on the final variant of each module
@@ -281,6 +334,9 @@ type ModuleBase struct {
 	checkbuildTarget string
 	blueprintDir     string
 
+	// Used by moduleGraphSingleton, only set when soong_build is run with --module-graph
+	graphDeps []moduleGraphDepInfo
+
 	hooks hooks
 }
 
@@ -450,6 +506,7 @@ func (a *ModuleBase) androidBaseContextFactory(ctx blueprint.BaseModuleContext)
 		target:        a.commonProperties.CompileTarget,
 		targetPrimary: a.commonProperties.CompilePrimary,
 		config:        ctx.Config().(Config),
+		envReader:     "//" + ctx.ModuleDir() + ":" + ctx.ModuleName(),
 	}
 }
 
@@ -463,6 +520,8 @@ func (a *ModuleBase) GenerateBuildActions(ctx blueprint.ModuleContext) {
 		missingDeps:            ctx.GetMissingDependencies(),
 	}
 
+	a.recordModuleGraphDeps(ctx)
+
 	if a.Enabled() {
 		a.module.GenerateAndroidBuildActions(androidCtx)
 		if ctx.Failed() {
@@ -471,6 +530,8 @@ func (a *ModuleBase) GenerateBuildActions(ctx blueprint.ModuleContext) {
 
 		a.installFiles = append(a.installFiles, androidCtx.installFiles...)
 		a.checkbuildFiles = append(a.checkbuildFiles, androidCtx.checkbuildFiles...)
+		a.installEntries = append(a.installEntries, androidCtx.installEntries...)
+		a.globs = append(a.globs, androidCtx.globs...)
 	}
 
 	if a == ctx.FinalModule().(Module).base() {
@@ -486,6 +547,7 @@ type androidBaseContextImpl struct {
 	targetPrimary bool
 	debug         bool
 	config        Config
+	envReader     string
 }
 
 type androidModuleContext struct {
@@ -494,11 +556,18 @@ type androidModuleContext struct {
 	installDeps     Paths
 	installFiles    Paths
 	checkbuildFiles Paths
+	installEntries  []installEntry
+	globs           []globResult
 	missingDeps     []string
 	module          Module
+
+	// Created on first use by checkHermetic
+	hermetic *hermeticChecker
 }
 
 func (a *androidModuleContext) ninjaError(outputs []string, err error) {
+	reportDiagnostic(a, a.target, "", SeverityError, strings.TrimSpace(err.Error()))
+
 	a.ModuleContext.Build(pctx, blueprint.BuildParams{
 		Rule:     ErrorRule,
 		Outputs:  outputs,
@@ -556,6 +625,10 @@ func (a *androidModuleContext) ModuleBuild(pctx blueprint.PackageContext, params
 		return
 	}
 
+	if a.AConfig().HermeticChecks() {
+		a.checkHermetic(bparams)
+	}
+
 	a.ModuleContext.Build(pctx, bparams)
 }
 
@@ -601,6 +674,10 @@ func (a *androidBaseContextImpl) Debug() bool {
 	return a.debug
 }
 
+func (a *androidBaseContextImpl) Getenv(key string) string {
+	return a.config.getenv(key, a.envReader)
+}
+
 func (a *androidBaseContextImpl) PrimaryArch() bool {
 	return a.target.Arch.ArchType == a.config.Targets[a.target.Os.Class][0].Arch.ArchType
 }
@@ -627,6 +704,16 @@ func (a *androidModuleContext) InstallFileName(installPath OutputPath, name stri
 	fullInstallPath := installPath.Join(a, name)
 	a.module.base().hooks.runInstallHooks(a, fullInstallPath, false)
 
+	if !a.module.base().commonProperties.SkipInstall {
+		// Record the install even if Make installs the file, so that the installed files manifest
+		// and the module graph include device files in builds that are embedded in Make
+		a.installEntries = append(a.installEntries, installEntry{
+			path:            fullInstallPath,
+			src:             srcPath,
+			installedByMake: !a.Host() && a.AConfig().SkipDeviceInstall(),
+		})
+	}
+
 	if !a.module.base().commonProperties.SkipInstall &&
 		(a.Host() || !a.AConfig().SkipDeviceInstall()) {
 
@@ -665,6 +752,15 @@ func (a *androidModuleContext) InstallSymlink(installPath OutputPath, name strin
 	fullInstallPath := installPath.Join(a, name)
 	a.module.base().hooks.runInstallHooks(a, fullInstallPath, true)
 
+	if !a.module.base().commonProperties.SkipInstall {
+		a.installEntries = append(a.installEntries, installEntry{
+			path:            fullInstallPath,
+			src:             srcPath,
+			symlink:         true,
+			installedByMake: !a.Host() && a.AConfig().SkipDeviceInstall(),
+		})
+	}
+
 	if !a.module.base().commonProperties.SkipInstall &&
 		(a.Host() || !a.AConfig().SkipDeviceInstall()) {
 
@@ -713,18 +809,32 @@ func findStringInSlice(str string, slice []string) int {
 
 func (ctx *androidModuleContext) ExpandSources(srcFiles, excludes []string) Paths {
 	prefix := PathForModuleSrc(ctx).String()
+	excludedModuleFiles := make(map[string]bool)
 	for i, e := range excludes {
 		j := findStringInSlice(e, srcFiles)
 		if j != -1 {
 			srcFiles = append(srcFiles[:j], srcFiles[j+1:]...)
 		}
 
+		if m, _ := SrcIsModule(e); m != "" {
+			for _, p := range ctx.sourceModuleOutputFiles(e) {
+				excludedModuleFiles[p.String()] = true
+			}
+			continue
+		}
+
 		excludes[i] = filepath.Join(prefix, e)
 	}
 
 	globbedSrcFiles := make(Paths, 0, len(srcFiles))
 	for _, s := range srcFiles {
-		if pathtools.IsGlob(s) {
+		if m, _ := SrcIsModule(s); m != "" {
+			for _, p := range ctx.sourceModuleOutputFiles(s) {
+				if !excludedModuleFiles[p.String()] {
+					globbedSrcFiles = append(globbedSrcFiles, p)
+				}
+			}
+		} else if pathtools.IsGlob(s) {
 			globbedSrcFiles = append(globbedSrcFiles, ctx.Glob(filepath.Join(prefix, s), excludes)...)
 		} else {
 			globbedSrcFiles = append(globbedSrcFiles, PathForModuleSrc(ctx, s))
@@ -801,6 +911,87 @@ func (c *buildTargetSingleton) GenerateBuildActions(ctx blueprint.SingletonConte
 	}
 }
 
+func init() {
+	RegisterSingletonType("owners", OwnersSingleton)
+}
+
+func OwnersSingleton() blueprint.Singleton {
+	return &ownersSingleton{}
+}
+
+// ownersSingleton writes module_owners.json, which lists the ownership metadata, Blueprint
+// directory and installed files of every module, so that the owner of an installed file can be
+// found.
+type ownersSingleton struct{}
+
+type moduleOwners struct {
+	Name          string
+	Dir           string
+	Owner         string   `json:",omitempty"`
+	Team          string   `json:",omitempty"`
+	Bug_component string   `json:",omitempty"`
+	Installs      []string `json:",omitempty"`
+}
+
+func (c *ownersSingleton) GenerateBuildActions(ctx blueprint.SingletonContext) {
+	owners := make(map[string]*moduleOwners)
+
+	ctx.VisitAllModules(func(module blueprint.Module) {
+		a, ok := module.(Module)
+		if !ok {
+			return
+		}
+
+		name := ctx.ModuleName(module)
+		entry := owners[name]
+		if entry == nil {
+			props := &a.base().commonProperties
+			entry = &moduleOwners{
+				Name:          name,
+				Dir:           ctx.ModuleDir(module),
+				Owner:         String(props.Owner),
+				Team:          String(props.Team),
+				Bug_component: String(props.Bug_component),
+			}
+			owners[name] = entry
+		}
+
+		entry.Installs = append(entry.Installs, a.base().installPaths()...)
+	})
+
+	names := make([]string, 0, len(owners))
+	for name := range owners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := make([]*moduleOwners, 0, len(names))
+	for _, name := range names {
+		sort.Strings(owners[name].Installs)
+		list = append(list, owners[name])
+	}
+
+	data, err := json.MarshalIndent(list, "", "    ")
+	if err != nil {
+		ctx.Errorf("%s", err)
+		return
+	}
+	data = append(data, '\n')
+
+	outFile := PathForOutput(ctx, "module_owners.json").String()
+	if ctx.Failed() {
+		return
+	}
+
+	if old, err := ioutil.ReadFile(outFile); err == nil && bytes.Equal(old, data) {
+		return
+	}
+
+	if err := ioutil.WriteFile(outFile, data, 0666); err != nil {
+		ctx.Errorf("%s", err)
+	}
+}
+
 type AndroidModulesByName struct {
 	slice []Module
 	ctx   interface {
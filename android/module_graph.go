@@ -0,0 +1,162 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+
+	"github.com/google/blueprint"
+)
+
+// This file implements the module graph dump requested with soong_build --module-graph=<file>.
+// Every variant of every module is written to a JSON file after all mutators have run, along
+// with its direct dependencies and the tags they were added with, so that tools can answer
+// questions about the build graph without parsing build.ninja.
+
+func init() {
+	RegisterSingletonType("module_graph", ModuleGraphSingleton)
+}
+
+func ModuleGraphSingleton() blueprint.Singleton {
+	return &moduleGraphSingleton{}
+}
+
+type moduleGraphSingleton struct{}
+
+type moduleGraphTarget struct {
+	Os   string
+	Arch string
+}
+
+type moduleGraphDep struct {
+	Name    string
+	Variant string
+	Tag     string `json:",omitempty"`
+}
+
+type moduleGraphEntry struct {
+	Name      string
+	Type      string
+	Blueprint string
+	Variant   string
+	Target    moduleGraphTarget
+	Enabled   bool
+	Deps      []moduleGraphDep `json:",omitempty"`
+	Installs  []string         `json:",omitempty"`
+	Outputs   []string         `json:",omitempty"`
+}
+
+type moduleGraphDepInfo struct {
+	module blueprint.Module
+	tag    blueprint.DependencyTag
+}
+
+// recordModuleGraphDeps saves the direct dependencies of a module variant and their tags for
+// moduleGraphSingleton, which cannot look up dependency tags itself.
+func (a *ModuleBase) recordModuleGraphDeps(ctx blueprint.ModuleContext) {
+	if ctx.Config().(Config).ModuleGraphFile == "" {
+		return
+	}
+
+	a.graphDeps = nil
+	ctx.VisitDirectDeps(func(m blueprint.Module) {
+		a.graphDeps = append(a.graphDeps, moduleGraphDepInfo{m, ctx.OtherModuleDependencyTag(m)})
+	})
+}
+
+// dependencyTagName returns a human readable name for a dependency tag.  Tags can provide their
+// own name by implementing fmt.Stringer.
+func dependencyTagName(tag blueprint.DependencyTag) string {
+	if tag == nil {
+		return ""
+	}
+	if s, ok := tag.(fmt.Stringer); ok {
+		return s.String()
+	}
+	if tag == prebuiltDependencyTag {
+		return "prebuilt"
+	}
+	return reflect.TypeOf(tag).String()
+}
+
+func (c *moduleGraphSingleton) GenerateBuildActions(ctx blueprint.SingletonContext) {
+	graphFile := ctx.Config().(Config).ModuleGraphFile
+	if graphFile == "" {
+		return
+	}
+
+	var entries []moduleGraphEntry
+
+	ctx.VisitAllModules(func(module blueprint.Module) {
+		entry := moduleGraphEntry{
+			Name:      ctx.ModuleName(module),
+			Type:      ctx.ModuleType(module),
+			Blueprint: ctx.BlueprintFile(module),
+			Variant:   ctx.ModuleSubDir(module),
+		}
+
+		if a, ok := module.(Module); ok {
+			base := a.base()
+			target := a.Target()
+			entry.Target = moduleGraphTarget{
+				Os:   target.Os.String(),
+				Arch: target.Arch.String(),
+			}
+			entry.Enabled = a.Enabled()
+			entry.Installs = base.installPaths()
+			entry.Outputs = base.checkbuildFiles.Strings()
+
+			for _, dep := range base.graphDeps {
+				entry.Deps = append(entry.Deps, moduleGraphDep{
+					Name:    ctx.ModuleName(dep.module),
+					Variant: ctx.ModuleSubDir(dep.module),
+					Tag:     dependencyTagName(dep.tag),
+				})
+			}
+		}
+
+		entries = append(entries, entry)
+	})
+
+	sort.Sort(moduleGraphEntries(entries))
+
+	data, err := json.MarshalIndent(entries, "", "    ")
+	if err != nil {
+		ctx.Errorf("failed to marshal module graph: %s", err.Error())
+		return
+	}
+
+	data = append(data, '\n')
+
+	err = ioutil.WriteFile(graphFile, data, 0666)
+	if err != nil {
+		ctx.Errorf("failed to write module graph: %s", err.Error())
+	}
+}
+
+type moduleGraphEntries []moduleGraphEntry
+
+func (e moduleGraphEntries) Len() int { return len(e) }
+func (e moduleGraphEntries) Less(i, j int) bool {
+	if e[i].Name != e[j].Name {
+		return e[i].Name < e[j].Name
+	}
+	return e[i].Variant < e[j].Variant
+}
+func (e moduleGraphEntries) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
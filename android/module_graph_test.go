@@ -0,0 +1,105 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestModuleGraph(t *testing.T) {
+	buildDir, err := ioutil.TempDir("", "soong_module_graph_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(buildDir)
+
+	// Device files are installed by Make, they must still be listed
+	config := TestConfig(buildDir)
+	config.inMake = true
+	config.ModuleGraphFile = filepath.Join(buildDir, "module_graph.json")
+
+	ctx := NewContext()
+	ctx.RegisterModuleType("sources", newSourcesModule)
+	ctx.RegisterModuleType("sources_defaults", newSourcesDefaultsModule)
+	ctx.RegisterModuleType("output", newOutputModule)
+	ctx.RegisterModuleType("install", newInstallModule)
+	ctx.MockFileSystem(map[string][]byte{
+		"Blueprints": []byte(`
+			sources_defaults {
+				name: "defaults",
+				srcs: [":gen"],
+			}
+
+			sources {
+				name: "foo",
+				defaults: ["defaults"],
+			}
+
+			output {
+				name: "gen",
+			}
+
+			install {
+				name: "bar",
+				installs: ["system/bin/bar"],
+			}
+		`),
+	})
+
+	_, errs := ctx.ParseBlueprintsFiles("Blueprints")
+	fail(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+	fail(t, errs)
+
+	data, err := ioutil.ReadFile(config.ModuleGraphFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []moduleGraphEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	byName := make(map[string]moduleGraphEntry)
+	for _, entry := range entries {
+		names = append(names, entry.Name)
+		byName[entry.Name] = entry
+	}
+	if expected := []string{"bar", "defaults", "foo", "gen"}; !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected modules %q, got %q", expected, names)
+	}
+
+	expectedDeps := []moduleGraphDep{
+		{Name: "defaults", Tag: "defaults"},
+		{Name: "gen", Tag: "source"},
+	}
+	if deps := byName["foo"].Deps; !reflect.DeepEqual(deps, expectedDeps) {
+		t.Errorf("expected foo deps %#v, got %#v", expectedDeps, deps)
+	}
+	if deps := byName["gen"].Deps; len(deps) != 0 {
+		t.Errorf("expected no gen deps, got %#v", deps)
+	}
+
+	expectedInstalls := []string{filepath.Join(buildDir, "system/bin/bar")}
+	if installs := byName["bar"].Installs; !reflect.DeepEqual(installs, expectedInstalls) {
+		t.Errorf("expected bar installs %q, got %q", expectedInstalls, installs)
+	}
+}
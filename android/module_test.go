@@ -0,0 +1,91 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOwnersSingleton(t *testing.T) {
+	buildDir, err := ioutil.TempDir("", "soong_owners_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(buildDir)
+
+	// Device files are installed by Make, they must still be listed
+	config := TestConfig(buildDir)
+	config.inMake = true
+
+	ctx := NewContext()
+	ctx.RegisterModuleType("owned", newInstallModule)
+	ctx.MockFileSystem(map[string][]byte{
+		"Blueprints": []byte(`
+			owned {
+				name: "foo",
+				owner: "foo-owners@example.com",
+				team: "media",
+				bug_component: "1234",
+				installs: ["system/bin/foo", "system/bin/foo2"],
+			}
+
+			owned {
+				name: "bar",
+			}
+		`),
+	})
+
+	_, errs := ctx.ParseBlueprintsFiles("Blueprints")
+	fail(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+	fail(t, errs)
+
+	data, err := ioutil.ReadFile(filepath.Join(buildDir, "module_owners.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var owners []moduleOwners
+	if err := json.Unmarshal(data, &owners); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []moduleOwners{
+		{
+			Name: "bar",
+			Dir:  ".",
+		},
+		{
+			Name:          "foo",
+			Dir:           ".",
+			Owner:         "foo-owners@example.com",
+			Team:          "media",
+			Bug_component: "1234",
+			Installs: []string{
+				filepath.Join(buildDir, "system/bin/foo"),
+				filepath.Join(buildDir, "system/bin/foo2"),
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(owners, expected) {
+		t.Errorf("expected\n%#v\ngot\n%#v", expected, owners)
+	}
+}
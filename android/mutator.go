@@ -24,7 +24,7 @@ import "github.com/google/blueprint"
 //   PostDeps
 
 func registerMutators() {
-	ctx := registerMutatorsContext{}
+	ctx := &registerMutatorsContext{}
 
 	register := func(funcs []RegisterMutatorFunc) {
 		for _, f := range funcs {
@@ -33,6 +33,7 @@ func registerMutators() {
 	}
 
 	ctx.TopDown("load_hooks", loadHookMutator).Parallel()
+	ctx.BottomUp("deprecated_properties", deprecatedPropertiesMutator).Parallel()
 	ctx.BottomUp("prebuilts", prebuiltMutator).Parallel()
 	ctx.BottomUp("defaults_deps", defaultsDepsMutator).Parallel()
 	ctx.TopDown("defaults", defaultsMutator).Parallel()
@@ -44,7 +45,9 @@ func registerMutators() {
 
 	register(preDeps)
 
+	ctx.depsAdded = true
 	ctx.BottomUp("deps", depsMutator).Parallel()
+	ctx.BottomUp("visibility", visibilityMutator).Parallel()
 
 	ctx.TopDown("prebuilt_select", PrebuiltSelectModuleMutator).Parallel()
 	ctx.BottomUp("prebuilt_replace", PrebuiltReplaceMutator).Parallel()
@@ -52,7 +55,10 @@ func registerMutators() {
 	register(postDeps)
 }
 
-type registerMutatorsContext struct{}
+type registerMutatorsContext struct {
+	// Set for the mutators that run from the deps mutator on
+	depsAdded bool
+}
 
 type RegisterMutatorsContext interface {
 	TopDown(name string, m AndroidTopDownMutator) MutatorHandle
@@ -80,6 +86,9 @@ type AndroidTopDownMutator func(TopDownMutatorContext)
 type TopDownMutatorContext interface {
 	blueprint.TopDownMutatorContext
 	androidBaseContext
+
+	ModuleWarningf(format string, args ...interface{})
+	PropertyWarningf(property, format string, args ...interface{})
 }
 
 type androidTopDownMutatorContext struct {
@@ -92,19 +101,30 @@ type AndroidBottomUpMutator func(BottomUpMutatorContext)
 type BottomUpMutatorContext interface {
 	blueprint.BottomUpMutatorContext
 	androidBaseContext
+
+	ModuleWarningf(format string, args ...interface{})
+	PropertyWarningf(property, format string, args ...interface{})
 }
 
 type androidBottomUpMutatorContext struct {
 	blueprint.BottomUpMutatorContext
 	androidBaseContextImpl
+
+	mutatorName string
+	// Set if the mutator runs before the deps mutator, where the variants it creates must be
+	// recorded in SplitMutators
+	recordSplits bool
 }
 
-func (registerMutatorsContext) BottomUp(name string, m AndroidBottomUpMutator) MutatorHandle {
+func (r *registerMutatorsContext) BottomUp(name string, m AndroidBottomUpMutator) MutatorHandle {
+	recordSplits := !r.depsAdded && name != "arch"
 	f := func(ctx blueprint.BottomUpMutatorContext) {
 		if a, ok := ctx.Module().(Module); ok {
 			actx := &androidBottomUpMutatorContext{
 				BottomUpMutatorContext: ctx,
 				androidBaseContextImpl: a.base().androidBaseContextFactory(ctx),
+				mutatorName:            name,
+				recordSplits:           recordSplits,
 			}
 			m(actx)
 		}
@@ -114,7 +134,7 @@ func (registerMutatorsContext) BottomUp(name string, m AndroidBottomUpMutator) M
 	return mutator
 }
 
-func (registerMutatorsContext) TopDown(name string, m AndroidTopDownMutator) MutatorHandle {
+func (r *registerMutatorsContext) TopDown(name string, m AndroidTopDownMutator) MutatorHandle {
 	f := func(ctx blueprint.TopDownMutatorContext) {
 		if a, ok := ctx.Module().(Module); ok {
 			actx := &androidTopDownMutatorContext{
@@ -129,6 +149,48 @@ func (registerMutatorsContext) TopDown(name string, m AndroidTopDownMutator) Mut
 	return mutator
 }
 
+func (a *androidBottomUpMutatorContext) CreateVariations(variations ...string) []blueprint.Module {
+	modules := a.BottomUpMutatorContext.CreateVariations(variations...)
+	a.recordSplit(modules)
+	markFirstVariant(modules)
+	return modules
+}
+
+func (a *androidBottomUpMutatorContext) CreateLocalVariations(variations ...string) []blueprint.Module {
+	modules := a.BottomUpMutatorContext.CreateLocalVariations(variations...)
+	a.recordSplit(modules)
+	markFirstVariant(modules)
+	return modules
+}
+
+// markFirstVariant sets NotFirstVariant on all but the first of the variants created by a mutator.
+func markFirstVariant(modules []blueprint.Module) {
+	if len(modules) < 2 {
+		return
+	}
+	for _, m := range modules[1:] {
+		if m, ok := m.(Module); ok {
+			m.base().commonProperties.NotFirstVariant = true
+		}
+	}
+}
+
+// recordSplit records the mutator in SplitMutators of the variants it created, if it created more
+// than one.  Dependencies added for module references in srcs properties only select the arch
+// variant, so a reference to a module that was also split by another mutator would resolve to an
+// arbitrary variant.
+func (a *androidBottomUpMutatorContext) recordSplit(modules []blueprint.Module) {
+	if !a.recordSplits || len(modules) < 2 {
+		return
+	}
+	for _, m := range modules {
+		if m, ok := m.(Module); ok {
+			props := &m.base().commonProperties
+			props.SplitMutators = append(append([]string(nil), props.SplitMutators...), a.mutatorName)
+		}
+	}
+}
+
 type MutatorHandle interface {
 	Parallel() MutatorHandle
 }
@@ -140,6 +202,7 @@ func (mutator *mutator) Parallel() MutatorHandle {
 
 func depsMutator(ctx BottomUpMutatorContext) {
 	if m, ok := ctx.Module().(Module); ok {
+		addSourceDeps(ctx, m)
 		m.DepsMutator(ctx)
 	}
 }
@@ -629,14 +629,7 @@ func PathForModuleRes(ctx ModuleContext, paths ...string) ModuleResPath {
 func PathForModuleInstall(ctx ModuleContext, paths ...string) OutputPath {
 	var outPaths []string
 	if ctx.Device() {
-		partition := "system"
-		if ctx.Proprietary() {
-			partition = "vendor"
-		}
-		if ctx.InstallInData() {
-			partition = "data"
-		}
-		outPaths = []string{"target", "product", ctx.AConfig().DeviceName(), partition}
+		outPaths = deviceInstallDir(ctx)
 	} else {
 		outPaths = []string{"host", ctx.Os().String() + "-x86"}
 	}
@@ -647,6 +640,24 @@ func PathForModuleInstall(ctx ModuleContext, paths ...string) OutputPath {
 	return PathForOutput(ctx, outPaths...)
 }
 
+// PathForModuleDeviceInstall returns a Path representing the install path for a module on the
+// device, even if the module is not built for a device target.
+func PathForModuleDeviceInstall(ctx ModuleContext, paths ...string) OutputPath {
+	outPaths := append(deviceInstallDir(ctx), paths...)
+	return PathForOutput(ctx, outPaths...)
+}
+
+func deviceInstallDir(ctx ModuleContext) []string {
+	partition := "system"
+	if ctx.Proprietary() {
+		partition = "vendor"
+	}
+	if ctx.InstallInData() {
+		partition = "data"
+	}
+	return []string{"target", "product", ctx.AConfig().DeviceName(), partition}
+}
+
 // validateSafePath validates a path that we trust (may contain ninja variables).
 // Ensures that each path component does not attempt to leave its component.
 func validateSafePath(ctx PathContext, paths ...string) string {
@@ -21,17 +21,22 @@ import "github.com/google/blueprint"
 
 var prebuiltDependencyTag blueprint.BaseDependencyTag
 
+// PrebuiltProperties are the properties of a prebuilt module.  It is exported so that prebuilt
+// defaults module types can include it.  It must not be included in defaults module types that
+// also include other properties named srcs.
+type PrebuiltProperties struct {
+	Srcs []string `android:"arch_variant"`
+	// When prefer is set to true the prebuilt will be used instead of any source module with
+	// a matching name.
+	Prefer *bool `android:"arch_variant"`
+
+	SourceExists bool `blueprint:"mutated"`
+	UsePrebuilt  bool `blueprint:"mutated"`
+}
+
 type Prebuilt struct {
-	Properties struct {
-		Srcs []string `android:"arch_variant"`
-		// When prefer is set to true the prebuilt will be used instead of any source module with
-		// a matching name.
-		Prefer bool `android:"arch_variant"`
-
-		SourceExists bool `blueprint:"mutated"`
-		UsePrebuilt  bool `blueprint:"mutated"`
-	}
-	module Module
+	Properties PrebuiltProperties
+	module     Module
 }
 
 func (p *Prebuilt) Name(name string) string {
@@ -113,7 +118,7 @@ func (p *Prebuilt) usePrebuilt(ctx TopDownMutatorContext, source Module) bool {
 	}
 
 	// TODO: use p.Properties.Name and ctx.ModuleDir to override preference
-	if p.Properties.Prefer {
+	if Bool(p.Properties.Prefer) {
 		return true
 	}
 
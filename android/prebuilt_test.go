@@ -109,6 +109,45 @@ var prebuiltsTests = []struct {
 			}`,
 		prebuilt: false,
 	},
+	{
+		name: "prebuilt preferred by defaults",
+		modules: `
+			prebuilt_defaults {
+				name: "prebuilt_defaults",
+				prefer: true,
+				srcs: ["prebuilt"],
+			}
+
+			source {
+				name: "bar",
+			}
+
+			prebuilt {
+				name: "bar",
+				defaults: ["prebuilt_defaults"],
+			}`,
+		prebuilt: true,
+	},
+	{
+		name: "prebuilt not preferred overrides defaults",
+		modules: `
+			prebuilt_defaults {
+				name: "prebuilt_defaults",
+				prefer: true,
+				srcs: ["prebuilt"],
+			}
+
+			source {
+				name: "bar",
+			}
+
+			prebuilt {
+				name: "bar",
+				defaults: ["prebuilt_defaults"],
+				prefer: false,
+			}`,
+		prebuilt: false,
+	},
 }
 
 func TestPrebuilts(t *testing.T) {
@@ -125,6 +164,7 @@ func TestPrebuilts(t *testing.T) {
 			ctx := NewContext()
 			ctx.RegisterModuleType("prebuilt", newPrebuiltModule)
 			ctx.RegisterModuleType("source", newSourceModule)
+			ctx.RegisterModuleType("prebuilt_defaults", newPrebuiltDefaultsModule)
 			ctx.MockFileSystem(map[string][]byte{
 				"Blueprints": []byte(`
 					source {
@@ -167,12 +207,14 @@ func TestPrebuilts(t *testing.T) {
 
 type prebuiltModule struct {
 	ModuleBase
+	DefaultableModule
 	prebuilt Prebuilt
 }
 
 func newPrebuiltModule() (blueprint.Module, []interface{}) {
 	m := &prebuiltModule{}
-	return InitAndroidModule(m, &m.prebuilt.Properties)
+	_, props := InitAndroidModule(m, &m.prebuilt.Properties)
+	return InitDefaultableModule(m, m, props...)
 }
 
 func (p *prebuiltModule) Name() string {
@@ -237,3 +279,19 @@ func fail(t *testing.T, errs []error) {
 		t.FailNow()
 	}
 }
+
+type prebuiltDefaultsModule struct {
+	ModuleBase
+	DefaultsModule
+}
+
+func newPrebuiltDefaultsModule() (blueprint.Module, []interface{}) {
+	m := &prebuiltDefaultsModule{}
+	return InitDefaultsModule(m, m, &PrebuiltProperties{})
+}
+
+func (d *prebuiltDefaultsModule) DepsMutator(ctx BottomUpMutatorContext) {
+}
+
+func (d *prebuiltDefaultsModule) GenerateAndroidBuildActions(ModuleContext) {
+}
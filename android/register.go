@@ -15,11 +15,18 @@
 package android
 
 import (
+	"reflect"
 	"sync"
 
 	"github.com/google/blueprint"
 )
 
+// This file contains the tables of module types, singletons and mutators that are registered with
+// every Blueprint context created by NewContext.  Any Go package linked into soong_build can add to
+// them from an init() function, including packages outside build/soong: a bootstrap_go_package
+// that sets pluginFor: ["soong_build"] is linked into soong_build by the bootstrap Blueprint if
+// its Android.bp file is reachable from the top level Android.bp.
+
 type moduleType struct {
 	name    string
 	factory blueprint.ModuleFactory
@@ -54,10 +61,24 @@ func RegisterSingletonType(name string, factory blueprint.SingletonFactory) {
 var registerMutatorsOnce sync.Once
 
 func NewContext() *blueprint.Context {
+	return newContext(nil)
+}
+
+// NewContextForConfig returns a context like NewContext whose module types also support the
+// product_variables properties for the custom product variables declared by config.
+func NewContextForConfig(config Config) *blueprint.Context {
+	return newContext(config.customVariablePropertiesType)
+}
+
+func newContext(customVariablePropertiesType reflect.Type) *blueprint.Context {
 	ctx := blueprint.NewContext()
 
 	for _, t := range moduleTypes {
-		ctx.RegisterModuleType(t.name, t.factory)
+		factory := t.factory
+		if customVariablePropertiesType != nil {
+			factory = customVariablesFactory(factory, customVariablePropertiesType)
+		}
+		ctx.RegisterModuleType(t.name, factory)
 	}
 
 	for _, t := range singletons {
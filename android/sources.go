@@ -0,0 +1,182 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/google/blueprint"
+	"github.com/google/blueprint/proptools"
+)
+
+// This file implements references to the output files of other modules in srcs properties.  An
+// entry of the form ":name" expands to the output files of module name, and an entry of the form
+// ":name{.tag}" expands to the output files of module name selected by tag.  Any property with
+// the android:"path" tag may contain module references; depsMutator adds a dependency on every
+// referenced module, and ExpandSources replaces the references with the output files.
+
+// OutputFileProducer is implemented by modules that can be referenced from srcs properties.  The
+// meaning of tag is defined by each module type; the empty tag selects the default output files.
+// The dependency only selects the arch variant of the referenced module, so a module that a
+// mutator other than arch splits into more than one variant, for example a cc_library with static
+// and shared variants, can't be referenced.
+type OutputFileProducer interface {
+	OutputFiles(tag string) (Paths, error)
+}
+
+type sourceDependencyTag struct {
+	blueprint.BaseDependencyTag
+}
+
+func (sourceDependencyTag) String() string {
+	return "source"
+}
+
+// SourceDepTag is the dependency tag used for the dependencies added for module references in
+// srcs properties.  Module types that visit their direct dependencies should ignore dependencies
+// with this tag, they are handled by ExpandSources.
+var SourceDepTag sourceDependencyTag
+
+// SrcIsModule returns the module name and the tag of an entry of the form ":name" or
+// ":name{.tag}" in a srcs property, or an empty module name if the entry is a path.
+func SrcIsModule(s string) (module, tag string) {
+	if len(s) < 2 || s[0] != ':' {
+		return "", ""
+	}
+	module = s[1:]
+	if i := strings.IndexByte(module, '{'); i > 0 && strings.HasSuffix(module, "}") {
+		module, tag = module[:i], module[i+1:len(module)-1]
+	}
+	return module, tag
+}
+
+// pathProperties returns the values of all properties with the android:"path" tag in a property
+// struct.
+func pathProperties(v reflect.Value) []string {
+	var ret []string
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		fieldValue := v.Field(i)
+		if proptools.HasTag(field, "android", "path") {
+			switch fieldValue.Kind() {
+			case reflect.String:
+				ret = append(ret, fieldValue.String())
+			case reflect.Slice:
+				if fieldValue.Type().Elem().Kind() == reflect.String {
+					for j := 0; j < fieldValue.Len(); j++ {
+						ret = append(ret, fieldValue.Index(j).String())
+					}
+				}
+			case reflect.Ptr:
+				if !fieldValue.IsNil() && fieldValue.Elem().Kind() == reflect.String {
+					ret = append(ret, fieldValue.Elem().String())
+				}
+			}
+			continue
+		}
+
+		if fieldValue.Kind() == reflect.Ptr && !fieldValue.IsNil() {
+			fieldValue = fieldValue.Elem()
+		}
+		if fieldValue.Kind() == reflect.Struct {
+			ret = append(ret, pathProperties(fieldValue)...)
+		}
+	}
+	return ret
+}
+
+// addSourceDeps adds a dependency on every module referenced from a property with the
+// android:"path" tag.  It runs from depsMutator, after the arch variant properties have been
+// merged into the general properties.
+func addSourceDeps(ctx BottomUpMutatorContext, m Module) {
+	// The properties of defaults modules are only used by the modules that extend them, which add
+	// their own dependencies
+	if _, ok := m.(Defaults); ok {
+		return
+	}
+
+	var deps []string
+	seen := make(map[string]bool)
+	for _, props := range m.base().generalProperties {
+		for _, s := range pathProperties(reflect.ValueOf(props).Elem()) {
+			if name, _ := SrcIsModule(s); name != "" && !seen[name] {
+				seen[name] = true
+				deps = append(deps, name)
+			}
+		}
+	}
+
+	if len(deps) == 0 {
+		return
+	}
+
+	// The referenced module may be of another type, and not have the variants created by the
+	// mutators of this module type, so only select the arch variant that matches this module.
+	// Modules that are not arch specific, like genrules, match any arch variant.
+	var variations []blueprint.Variation
+	if m.base().ArchSpecific() {
+		variations = append(variations, blueprint.Variation{
+			Mutator:   "arch",
+			Variation: m.base().Target().String(),
+		})
+	}
+	ctx.AddFarVariationDependencies(variations, SourceDepTag, deps...)
+}
+
+// sourceModuleOutputFiles returns the output files of the module referenced by a ":name{.tag}"
+// entry in a srcs property.
+func (ctx *androidModuleContext) sourceModuleOutputFiles(s string) Paths {
+	name, tag := SrcIsModule(s)
+
+	var ret Paths
+	found := false
+	ctx.VisitDirectDeps(func(m blueprint.Module) {
+		if found || ctx.OtherModuleName(m) != name || ctx.OtherModuleDependencyTag(m) != SourceDepTag {
+			return
+		}
+		found = true
+
+		if a, ok := m.(Module); ok && len(a.base().commonProperties.SplitMutators) > 0 {
+			ctx.ModuleErrorf("module %q referenced by %q has more than one variant for the %s mutator, "+
+				"reference a module that has one variant for each target instead",
+				name, s, strings.Join(a.base().commonProperties.SplitMutators, ", "))
+			return
+		}
+
+		producer, ok := m.(OutputFileProducer)
+		if !ok {
+			ctx.ModuleErrorf("module %q referenced by %q does not produce output files", name, s)
+			return
+		}
+		paths, err := producer.OutputFiles(tag)
+		if err != nil {
+			ctx.ModuleErrorf("%q: %s", s, err.Error())
+			return
+		}
+		ret = paths
+	})
+
+	if !found {
+		ctx.ModuleErrorf("missing dependency on %q for %q, is the property tagged with android:\"path\"?",
+			name, s)
+	}
+
+	return ret
+}
@@ -0,0 +1,279 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/blueprint"
+)
+
+var srcIsModuleTestCases = []struct {
+	in     string
+	module string
+	tag    string
+}{
+	{
+		in: "foo.c",
+	},
+	{
+		in: "src/*.java",
+	},
+	{
+		in: ":",
+	},
+	{
+		in:     ":foo",
+		module: "foo",
+	},
+	{
+		in:     ":foo{.h}",
+		module: "foo",
+		tag:    ".h",
+	},
+	{
+		in:     ":foo{}",
+		module: "foo",
+	},
+	{
+		in:     ":foo{.h",
+		module: "foo{.h",
+	},
+}
+
+func TestSrcIsModule(t *testing.T) {
+	for _, test := range srcIsModuleTestCases {
+		module, tag := SrcIsModule(test.in)
+		if module != test.module || tag != test.tag {
+			t.Errorf("%q: expected %q, %q, got %q, %q", test.in, test.module, test.tag, module, tag)
+		}
+	}
+}
+
+func TestPathProperties(t *testing.T) {
+	cmd := ":tool"
+	props := struct {
+		Srcs         []string `android:"arch_variant,path"`
+		Exclude_srcs []string `android:"path"`
+		Cflags       []string `android:"arch_variant"`
+		Nested       struct {
+			Tool *string `android:"path"`
+			Name string
+		}
+		Unset *string `android:"path"`
+	}{
+		Srcs:         []string{"a.c", ":gen"},
+		Exclude_srcs: []string{":gen{.h}"},
+		Cflags:       []string{":notapath"},
+	}
+	props.Nested.Tool = &cmd
+	props.Nested.Name = ":notapath"
+
+	expected := []string{"a.c", ":gen", ":gen{.h}", ":tool"}
+	got := pathProperties(reflect.ValueOf(&props).Elem())
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestSourceDeps(t *testing.T) {
+	buildDir, err := ioutil.TempDir("", "soong_sources_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(buildDir)
+
+	ctx := NewContext()
+	ctx.RegisterModuleType("sources", newSourcesModule)
+	ctx.RegisterModuleType("sources_defaults", newSourcesDefaultsModule)
+	ctx.RegisterModuleType("output", newOutputModule)
+	ctx.MockFileSystem(map[string][]byte{
+		"Blueprints": []byte(`
+			sources_defaults {
+				name: "defaults",
+				srcs: [":gen"],
+			}
+
+			sources_defaults {
+				name: "unused_defaults",
+				srcs: [":missing"],
+			}
+
+			sources {
+				name: "foo",
+				defaults: ["defaults"],
+			}
+
+			output {
+				name: "gen",
+			}
+		`),
+	})
+
+	_, errs := ctx.ParseBlueprintsFiles("Blueprints")
+	fail(t, errs)
+	_, errs = ctx.PrepareBuildActions(TestConfig(buildDir))
+	fail(t, errs)
+
+	foo := findModule(ctx, "foo").(*sourcesModule)
+	expected := []string{filepath.Join(buildDir, "gen.out")}
+	if !reflect.DeepEqual(foo.srcs.Strings(), expected) {
+		t.Errorf("expected srcs %q, got %q", expected, foo.srcs.Strings())
+	}
+}
+
+func init() {
+	PreDepsMutators(func(ctx RegisterMutatorsContext) {
+		ctx.BottomUp("sources_test_variants", sourcesTestVariantsMutator).Parallel()
+	})
+}
+
+// sourcesTestVariantsMutator splits variantsModules into the variants listed in their variants
+// property.
+func sourcesTestVariantsMutator(ctx BottomUpMutatorContext) {
+	if m, ok := ctx.Module().(*variantsModule); ok && len(m.properties.Variants) > 0 {
+		ctx.CreateLocalVariations(m.properties.Variants...)
+	}
+}
+
+func TestSourceDepsMultipleVariants(t *testing.T) {
+	buildDir, err := ioutil.TempDir("", "soong_sources_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(buildDir)
+
+	ctx := NewContext()
+	ctx.RegisterModuleType("sources", newSourcesModule)
+	ctx.RegisterModuleType("variants", newVariantsModule)
+	ctx.MockFileSystem(map[string][]byte{
+		"Blueprints": []byte(`
+			sources {
+				name: "foo",
+				srcs: [":single"],
+			}
+
+			sources {
+				name: "bar",
+				srcs: [":multiple"],
+			}
+
+			variants {
+				name: "single",
+				variants: ["a"],
+			}
+
+			variants {
+				name: "multiple",
+				variants: ["a", "b"],
+			}
+		`),
+	})
+
+	_, errs := ctx.ParseBlueprintsFiles("Blueprints")
+	fail(t, errs)
+	_, errs = ctx.PrepareBuildActions(TestConfig(buildDir))
+
+	expectedErr := `module "multiple" referenced by ":multiple" has more than one variant for the ` +
+		`sources_test_variants mutator`
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), expectedErr) {
+		t.Fatalf("expected one error containing %q, got %q", expectedErr, errs)
+	}
+
+	foo := findModule(ctx, "foo").(*sourcesModule)
+	expected := []string{filepath.Join(buildDir, "gen.out")}
+	if !reflect.DeepEqual(foo.srcs.Strings(), expected) {
+		t.Errorf("expected srcs %q, got %q", expected, foo.srcs.Strings())
+	}
+}
+
+type sourcesProperties struct {
+	Srcs []string `android:"path"`
+}
+
+type sourcesModule struct {
+	ModuleBase
+	DefaultableModule
+	properties sourcesProperties
+	srcs       Paths
+}
+
+func newSourcesModule() (blueprint.Module, []interface{}) {
+	m := &sourcesModule{}
+	_, props := InitAndroidModule(m, &m.properties)
+	return InitDefaultableModule(m, m, props...)
+}
+
+func (m *sourcesModule) DepsMutator(ctx BottomUpMutatorContext) {
+}
+
+func (m *sourcesModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	m.srcs = ctx.ExpandSources(m.properties.Srcs, nil)
+}
+
+type sourcesDefaultsModule struct {
+	ModuleBase
+	DefaultsModule
+}
+
+func newSourcesDefaultsModule() (blueprint.Module, []interface{}) {
+	m := &sourcesDefaultsModule{}
+	return InitDefaultsModule(m, m, &sourcesProperties{})
+}
+
+func (m *sourcesDefaultsModule) DepsMutator(ctx BottomUpMutatorContext) {
+}
+
+func (m *sourcesDefaultsModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+}
+
+type outputModule struct {
+	ModuleBase
+	out Path
+}
+
+func newOutputModule() (blueprint.Module, []interface{}) {
+	m := &outputModule{}
+	return InitAndroidModule(m)
+}
+
+func (m *outputModule) DepsMutator(ctx BottomUpMutatorContext) {
+}
+
+func (m *outputModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	m.out = PathForOutput(ctx, "gen.out")
+}
+
+func (m *outputModule) OutputFiles(tag string) (Paths, error) {
+	return Paths{m.out}, nil
+}
+
+// variantsModule is an outputModule that sourcesTestVariantsMutator splits into variants.
+type variantsModule struct {
+	outputModule
+	properties struct {
+		Variants []string
+	}
+}
+
+func newVariantsModule() (blueprint.Module, []interface{}) {
+	m := &variantsModule{}
+	return InitAndroidModule(m, &m.properties)
+}
@@ -17,9 +17,12 @@ package android
 import (
 	"fmt"
 	"reflect"
+	"regexp"
 	"runtime"
+	"sort"
 	"strings"
 
+	"github.com/google/blueprint"
 	"github.com/google/blueprint/proptools"
 )
 
@@ -75,7 +78,36 @@ type variableProperties struct {
 
 var zeroProductVariables variableProperties
 
+// customVariableProperties are the properties that can be set for each product variable declared
+// in the Custom_variables section of the product variables file, for example:
+//
+//	product_variables: {
+//	    my_feature: {
+//	        cflags: ["-DMY_FEATURE=%s"],
+//	    },
+//	},
+//
+// Only the properties that are set are appended to the module's properties, so it is an error to
+// set one that the module type does not support.
+type customVariableProperties struct {
+	Cflags            []string `android:"arch_variant"`
+	Cppflags          []string `android:"arch_variant"`
+	Conlyflags        []string `android:"arch_variant"`
+	Asflags           []string `android:"arch_variant"`
+	Ldflags           []string `android:"arch_variant"`
+	Srcs              []string `android:"arch_variant"`
+	Exclude_srcs      []string `android:"arch_variant"`
+	Shared_libs       []string `android:"arch_variant"`
+	Static_libs       []string `android:"arch_variant"`
+	Whole_static_libs []string `android:"arch_variant"`
+	Enabled           *bool    `android:"arch_variant"`
+}
+
 type productVariables struct {
+	// Product variables files, relative to the source directory, that this file is merged on
+	// top of.  See variable_inherits.go.
+	Inherits []string `json:",omitempty"`
+
 	// Suffix to add to generated Makefiles
 	Make_suffix *string `json:",omitempty"`
 
@@ -94,8 +126,26 @@ type productVariables struct {
 	DeviceSecondaryCpuVariant  *string   `json:",omitempty"`
 	DeviceSecondaryAbi         *[]string `json:",omitempty"`
 
+	NativeBridgeArch         *string   `json:",omitempty"`
+	NativeBridgeArchVariant  *string   `json:",omitempty"`
+	NativeBridgeCpuVariant   *string   `json:",omitempty"`
+	NativeBridgeAbi          *[]string `json:",omitempty"`
+	NativeBridgeRelativePath *string   `json:",omitempty"`
+
+	NativeBridgeSecondaryArch         *string   `json:",omitempty"`
+	NativeBridgeSecondaryArchVariant  *string   `json:",omitempty"`
+	NativeBridgeSecondaryCpuVariant   *string   `json:",omitempty"`
+	NativeBridgeSecondaryAbi          *[]string `json:",omitempty"`
+	NativeBridgeSecondaryRelativePath *string   `json:",omitempty"`
+
+	// ArchVariantsFile is a JSON file, relative to the source directory, that declares
+	// additional arch variants, cpu variants and arch features.
+	ArchVariantsFile *string `json:",omitempty"`
+
 	HostArch          *string `json:",omitempty"`
 	HostSecondaryArch *string `json:",omitempty"`
+	HostBionic        *bool   `json:",omitempty"`
+	HostMusl          *bool   `json:",omitempty"`
 
 	CrossHost              *string `json:",omitempty"`
 	CrossHostArch          *string `json:",omitempty"`
@@ -122,6 +172,19 @@ type productVariables struct {
 	SanitizeHost       []string `json:",omitempty"`
 	SanitizeDevice     []string `json:",omitempty"`
 	SanitizeDeviceArch []string `json:",omitempty"`
+
+	// If set, only these environment variables may be read while generating the build manifest
+	EnvAllowlist *[]string `json:",omitempty"`
+
+	// Environment variables that may not be read while generating the build manifest
+	EnvDenylist []string `json:",omitempty"`
+
+	// Directories, including their subdirectories, in which warnings are errors
+	WarningsAsErrorsDirs []string `json:",omitempty"`
+
+	// Boolean or string product variables that are not known to Soong, keyed by the name used
+	// in product_variables properties.
+	Custom_variables map[string]interface{} `json:",omitempty"`
 }
 
 func boolPtr(v bool) *bool {
@@ -200,14 +263,171 @@ func variableMutator(mctx BottomUpMutatorContext) {
 
 		a.setVariableProperties(mctx, property, variableValue, val.Interface())
 	}
+
+	a.setCustomVariableProperties(mctx)
+}
+
+func (a *ModuleBase) setCustomVariableProperties(mctx BottomUpMutatorContext) {
+	config := mctx.Config().(Config)
+	if config.customVariablePropertiesType == nil || a.customVariableProperties == nil {
+		return
+	}
+
+	if t := reflect.TypeOf(a.customVariableProperties).Elem(); t != config.customVariablePropertiesType {
+		panic(fmt.Errorf("module %q was created for different custom product variables", mctx.ModuleName()))
+	}
+
+	values := config.ProductVariables.Custom_variables
+	variableValues := reflect.ValueOf(a.customVariableProperties).Elem().FieldByName("Product_variables")
+
+	for i := 0; i < variableValues.NumField(); i++ {
+		variableValue := variableValues.Field(i)
+		name := proptools.PropertyNameForField(variableValues.Type().Field(i).Name)
+		property := "product_variables." + name
+
+		// Check that the variable was set for the product
+		val, ok := values[name]
+		if !ok {
+			continue
+		}
+
+		// For bools, check that the value is true
+		if b, ok := val.(bool); ok && !b {
+			continue
+		}
+
+		// Check if any properties were set for the module
+		variableValue = nonZeroProperties(variableValue)
+		if variableValue.NumField() == 0 {
+			continue
+		}
+
+		a.setVariableProperties(mctx, property, variableValue, val)
+	}
+}
+
+// nonZeroProperties returns an addressable copy of a property struct that only contains the fields
+// that are set.
+func nonZeroProperties(v reflect.Value) reflect.Value {
+	var fields []reflect.StructField
+	var values []reflect.Value
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		if reflect.DeepEqual(field.Interface(), reflect.Zero(field.Type()).Interface()) {
+			continue
+		}
+		fields = append(fields, v.Type().Field(i))
+		values = append(values, field)
+	}
+
+	ret := reflect.New(reflect.StructOf(fields)).Elem()
+	for i, value := range values {
+		ret.Field(i).Set(value)
+	}
+	return ret
+}
+
+var customVariableNameRegexp = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// newCustomVariablePropertiesType checks the custom product variables declared by the product and
+// creates the type of the property struct that holds the product_variables properties for them.
+// It returns nil if the product did not declare any custom variables.
+func newCustomVariablePropertiesType(variables map[string]interface{}) (reflect.Type, error) {
+	if len(variables) == 0 {
+		return nil, nil
+	}
+
+	var names []string
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	builtins := reflect.TypeOf(zeroProductVariables.Product_variables)
+
+	var fields []reflect.StructField
+	for _, name := range names {
+		if !customVariableNameRegexp.MatchString(name) {
+			return nil, fmt.Errorf("invalid custom product variable name %q", name)
+		}
+
+		fieldName := proptools.FieldNameForProperty(name)
+		if _, ok := builtins.FieldByName(fieldName); ok {
+			return nil, fmt.Errorf("custom product variable %q conflicts with a built in product variable", name)
+		}
+
+		switch variables[name].(type) {
+		case bool, string:
+		default:
+			return nil, fmt.Errorf("custom product variable %q must be a bool or a string, got %T",
+				name, variables[name])
+		}
+
+		fields = append(fields, reflect.StructField{
+			Name: fieldName,
+			Type: reflect.TypeOf(customVariableProperties{}),
+			Tag:  `android:"arch_variant"`,
+		})
+	}
+
+	return reflect.StructOf([]reflect.StructField{
+		{
+			Name: "Product_variables",
+			Type: reflect.StructOf(fields),
+			Tag:  `android:"arch_variant"`,
+		},
+	}), nil
+}
+
+// customVariablesFactory wraps a module factory to add a property struct of type typ, created by
+// newCustomVariablePropertiesType, to the modules it creates.
+func customVariablesFactory(factory blueprint.ModuleFactory, typ reflect.Type) blueprint.ModuleFactory {
+	return func() (blueprint.Module, []interface{}) {
+		m, props := factory()
+		module, ok := m.(Module)
+		if !ok {
+			return m, props
+		}
+		base := module.base()
+
+		p := reflect.New(typ).Interface()
+		added := []interface{}{p}
+		base.propertyStructs = append(base.propertyStructs, p)
+
+		if base.customizableProperties != nil {
+			// Arch modules can also set the custom product variables in arch specific properties
+			archProps := newArchProperties(typ)
+			base.generalProperties = append(base.generalProperties, p)
+			base.archProperties = append(base.archProperties, archProps)
+			if archProps != nil {
+				added = append(added, archProps)
+			}
+			base.customizableProperties = append(base.customizableProperties, added...)
+		}
+
+		if d, ok := m.(Defaultable); ok {
+			d.addProperties(added...)
+		}
+
+		// Defaults modules only pass the properties on to the modules that use them
+		if _, ok := m.(Defaults); !ok {
+			base.customVariableProperties = p
+		}
+
+		return m, append(props, added...)
+	}
 }
 
 func (a *ModuleBase) setVariableProperties(ctx BottomUpMutatorContext,
 	prefix string, productVariablePropertyValue reflect.Value, variableValue interface{}) {
 
-	printfIntoProperties(productVariablePropertyValue, variableValue)
+	err := printfIntoProperties(productVariablePropertyValue, variableValue)
+	if err != nil {
+		ctx.PropertyErrorf(prefix, "%s", err.Error())
+		return
+	}
 
-	err := proptools.AppendMatchingProperties(a.generalProperties,
+	err = proptools.AppendMatchingProperties(a.generalProperties,
 		productVariablePropertyValue.Addr().Interface(), nil)
 	if err != nil {
 		if propertyErr, ok := err.(*proptools.ExtendPropertyError); ok {
@@ -218,7 +438,7 @@ func (a *ModuleBase) setVariableProperties(ctx BottomUpMutatorContext,
 	}
 }
 
-func printfIntoProperties(productVariablePropertyValue reflect.Value, variableValue interface{}) {
+func printfIntoProperties(productVariablePropertyValue reflect.Value, variableValue interface{}) error {
 	for i := 0; i < productVariablePropertyValue.NumField(); i++ {
 		propertyValue := productVariablePropertyValue.Field(i)
 		kind := propertyValue.Kind()
@@ -228,38 +448,56 @@ func printfIntoProperties(productVariablePropertyValue reflect.Value, variableVa
 			}
 			propertyValue = propertyValue.Elem()
 		}
+		var err error
 		switch propertyValue.Kind() {
 		case reflect.String:
-			printfIntoProperty(propertyValue, variableValue)
+			err = printfIntoProperty(propertyValue, variableValue)
 		case reflect.Slice:
-			for j := 0; j < propertyValue.Len(); j++ {
-				printfIntoProperty(propertyValue.Index(j), variableValue)
+			for j := 0; j < propertyValue.Len() && err == nil; j++ {
+				err = printfIntoProperty(propertyValue.Index(j), variableValue)
 			}
 		case reflect.Bool:
 			// Nothing
 		case reflect.Struct:
-			printfIntoProperties(propertyValue, variableValue)
+			err = printfIntoProperties(propertyValue, variableValue)
 		default:
 			panic(fmt.Errorf("unsupported field kind %q", propertyValue.Kind()))
 		}
+		if err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
-func printfIntoProperty(propertyValue reflect.Value, variableValue interface{}) {
+func printfIntoProperty(propertyValue reflect.Value, variableValue interface{}) error {
 	s := propertyValue.String()
-	// For now, we only support int formats
-	var i int
-	if strings.Contains(s, "%d") {
-		switch v := variableValue.(type) {
-		case int:
-			i = v
-		case bool:
+	// For now, we only support %s for string values and %d for int and bool values.  Other
+	// formats, or a format that doesn't match the type of the value, are left alone.
+	switch v := variableValue.(type) {
+	case string:
+		if strings.Contains(s, "%d") {
+			return fmt.Errorf("%q: %%d cannot be used with string value %q", s, v)
+		}
+		if strings.Contains(s, "%s") {
+			propertyValue.Set(reflect.ValueOf(fmt.Sprintf(s, v)))
+		}
+	case int:
+		if strings.Contains(s, "%d") {
+			propertyValue.Set(reflect.ValueOf(fmt.Sprintf(s, v)))
+		}
+	case bool:
+		if strings.Contains(s, "%d") {
+			i := 0
 			if v {
 				i = 1
 			}
-		default:
-			panic(fmt.Errorf("unsupported type %T", variableValue))
+			propertyValue.Set(reflect.ValueOf(fmt.Sprintf(s, i)))
+		}
+	default:
+		if strings.Contains(s, "%d") || strings.Contains(s, "%s") {
+			return fmt.Errorf("%q: unsupported product variable type %T", s, variableValue)
 		}
-		propertyValue.Set(reflect.ValueOf(fmt.Sprintf(s, i)))
 	}
+	return nil
 }
@@ -0,0 +1,157 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// This file implements layered product variables files.  A product variables file may list other
+// product variables files in Inherits, with paths relative to the source directory.  The inherited
+// files are loaded in order, each one merged on top of the previous ones, and the inheriting file
+// is merged on top of the result:
+//  - pointer values, including pointers to lists, replace the inherited value when set
+//  - lists replace the inherited list when set, an empty list clears it
+//  - maps are merged, values in the inheriting file replace inherited values with the same key
+//
+// The resolved product variables are written to soong.variables.resolved in the build directory
+// when they change.
+
+const resolvedProductVariablesFileName = "soong.variables.resolved"
+
+// writeResolvedProductVariables writes the resolved product variables to filename, unless it
+// already contains them, so that the file is only touched when the product variables change.
+func writeResolvedProductVariables(variables *productVariables, filename string) error {
+	data, err := configFileData(variables)
+	if err != nil {
+		return err
+	}
+
+	if old, err := ioutil.ReadFile(filename); err == nil && bytes.Equal(old, data) {
+		return nil
+	}
+
+	return saveToConfigFile(variables, filename)
+}
+
+// resolveProductVariablesInherits replaces variables with the result of merging it on top of the
+// files listed in its Inherits property.  It returns the list of files that were loaded.
+func resolveProductVariablesInherits(variables *productVariables, srcDir string) ([]string, error) {
+	var deps []string
+	ret, err := resolveInherits(*variables, srcDir, nil, &deps)
+	if err != nil {
+		return nil, err
+	}
+	*variables = ret
+	return deps, nil
+}
+
+func resolveInherits(variables productVariables, srcDir string, chain []string,
+	deps *[]string) (productVariables, error) {
+
+	inherits := variables.Inherits
+	variables.Inherits = nil
+	if len(inherits) == 0 {
+		return variables, nil
+	}
+
+	var ret productVariables
+	for _, file := range inherits {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(srcDir, file)
+		}
+
+		if inList(file, chain) {
+			return productVariables{}, fmt.Errorf("product variables file %s inherits itself: %s",
+				file, strings.Join(append(chain, file), " -> "))
+		}
+
+		inherited, err := loadInheritedProductVariables(file)
+		if err != nil {
+			return productVariables{}, err
+		}
+		if !inList(file, *deps) {
+			*deps = append(*deps, file)
+		}
+
+		inherited, err = resolveInherits(inherited, srcDir, append(chain, file), deps)
+		if err != nil {
+			return productVariables{}, err
+		}
+
+		mergeProductVariables(&ret, &inherited)
+	}
+
+	mergeProductVariables(&ret, &variables)
+	return ret, nil
+}
+
+func loadInheritedProductVariables(file string) (productVariables, error) {
+	var ret productVariables
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return ret, fmt.Errorf("inherited product variables file: %s", err.Error())
+	}
+
+	err = decodeConfigStrict(file, data, &ret)
+	if err != nil {
+		return ret, fmt.Errorf("inherited product variables file: %s", err.Error())
+	}
+
+	return ret, nil
+}
+
+// mergeProductVariables merges src on top of dst.
+func mergeProductVariables(dst, src *productVariables) {
+	mergeValues(reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem())
+}
+
+func mergeValues(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Struct:
+		for i := 0; i < src.NumField(); i++ {
+			if src.Type().Field(i).PkgPath != "" {
+				continue
+			}
+			mergeValues(dst.Field(i), src.Field(i))
+		}
+	case reflect.Ptr:
+		if !src.IsNil() {
+			dst.Set(src)
+		}
+	case reflect.Slice:
+		// A list that is not in the file is nil, an empty list in the file is not
+		if !src.IsNil() {
+			dst.Set(src)
+		}
+	case reflect.Map:
+		if src.Len() > 0 {
+			if dst.IsNil() {
+				dst.Set(reflect.MakeMap(dst.Type()))
+			}
+			for _, key := range src.MapKeys() {
+				dst.SetMapIndex(key, src.MapIndex(key))
+			}
+		}
+	default:
+		panic(fmt.Errorf("unsupported product variable kind %s", src.Kind()))
+	}
+}
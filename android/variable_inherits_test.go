@@ -0,0 +1,203 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var productVariablesInheritsTestCases = []struct {
+	name      string
+	files     map[string]string
+	variables string
+	expected  string
+	deps      []string
+	err       bool
+}{
+	{
+		name:      "no inherits",
+		variables: `{"DeviceName": "foo"}`,
+		expected:  `{"DeviceName": "foo"}`,
+	},
+	{
+		name: "merge",
+		files: map[string]string{
+			"board.json": `{
+				"DeviceArch": "arm64",
+				"DeviceAbi": ["arm64-v8a"],
+				"SanitizeDevice": ["address"],
+				"Custom_variables": {"board": "a", "feature": true}
+			}`,
+			"product.json": `{
+				"Inherits": ["board.json"],
+				"DeviceName": "base",
+				"DeviceAbi": ["arm64-v8a", "armeabi-v7a"],
+				"Custom_variables": {"board": "b"}
+			}`,
+		},
+		variables: `{
+			"Inherits": ["product.json"],
+			"DeviceName": "foo",
+			"SanitizeDevice": ["integer"]
+		}`,
+		expected: `{
+			"DeviceName": "foo",
+			"DeviceArch": "arm64",
+			"DeviceAbi": ["arm64-v8a", "armeabi-v7a"],
+			"SanitizeDevice": ["integer"],
+			"Custom_variables": {"board": "b", "feature": true}
+		}`,
+		deps: []string{"product.json", "board.json"},
+	},
+	{
+		name: "order",
+		files: map[string]string{
+			"a.json": `{"DeviceName": "a", "WarningsAsErrorsDirs": ["a"]}`,
+			"b.json": `{"DeviceName": "b", "WarningsAsErrorsDirs": ["b"]}`,
+		},
+		variables: `{"Inherits": ["a.json", "b.json"]}`,
+		expected:  `{"DeviceName": "b", "WarningsAsErrorsDirs": ["b"]}`,
+		deps:      []string{"a.json", "b.json"},
+	},
+	{
+		name: "clear list",
+		files: map[string]string{
+			"board.json": `{"SanitizeDevice": ["address"], "WarningsAsErrorsDirs": ["a"]}`,
+		},
+		variables: `{"Inherits": ["board.json"], "SanitizeDevice": []}`,
+		expected:  `{"SanitizeDevice": [], "WarningsAsErrorsDirs": ["a"]}`,
+		deps:      []string{"board.json"},
+	},
+
+	// Errors
+	{
+		name:      "missing file",
+		variables: `{"Inherits": ["missing.json"]}`,
+		err:       true,
+	},
+	{
+		name: "cycle",
+		files: map[string]string{
+			"a.json": `{"Inherits": ["b.json"]}`,
+			"b.json": `{"Inherits": ["a.json"]}`,
+		},
+		variables: `{"Inherits": ["a.json"]}`,
+		err:       true,
+	},
+}
+
+func TestProductVariablesInherits(t *testing.T) {
+	for _, test := range productVariablesInheritsTestCases {
+		func() {
+			srcDir, err := ioutil.TempDir("", "soong_variable_inherits_test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(srcDir)
+
+			for name, contents := range test.files {
+				err := ioutil.WriteFile(filepath.Join(srcDir, name), []byte(contents), 0666)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var variables, expected productVariables
+			if err := json.Unmarshal([]byte(test.variables), &variables); err != nil {
+				t.Fatalf("%s: %s", test.name, err.Error())
+			}
+
+			deps, err := resolveProductVariablesInherits(&variables, srcDir)
+			if err != nil {
+				if !test.err {
+					t.Errorf("%s: unexpected error %s", test.name, err.Error())
+				}
+				return
+			} else if test.err {
+				t.Errorf("%s: expected error", test.name)
+				return
+			}
+
+			if err := json.Unmarshal([]byte(test.expected), &expected); err != nil {
+				t.Fatalf("%s: %s", test.name, err.Error())
+			}
+			if !reflect.DeepEqual(variables, expected) {
+				got, _ := json.Marshal(variables)
+				want, _ := json.Marshal(expected)
+				t.Errorf("%s: expected\n%s\ngot\n%s", test.name, want, got)
+			}
+
+			var expectedDeps []string
+			for _, dep := range test.deps {
+				expectedDeps = append(expectedDeps, filepath.Join(srcDir, dep))
+			}
+			if !reflect.DeepEqual(deps, expectedDeps) {
+				t.Errorf("%s: expected deps %q, got %q", test.name, expectedDeps, deps)
+			}
+		}()
+	}
+}
+
+func TestWriteResolvedProductVariables(t *testing.T) {
+	buildDir, err := ioutil.TempDir("", "soong_variable_inherits_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(buildDir)
+
+	filename := filepath.Join(buildDir, resolvedProductVariablesFileName)
+	variables := productVariables{DeviceName: stringPtr("generic")}
+
+	if err := writeResolvedProductVariables(&variables, filename); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rewriting the same variables must not touch the file
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(filename, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeResolvedProductVariables(&variables, filename); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(filename); err != nil {
+		t.Fatal(err)
+	} else if !info.ModTime().Equal(old) {
+		t.Errorf("expected unchanged product variables not to be rewritten")
+	}
+
+	variables.DeviceName = stringPtr("other")
+	if err := writeResolvedProductVariables(&variables, filename); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written productVariables
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatal(err)
+	}
+	if name := String(written.DeviceName); name != "other" {
+		t.Errorf("expected changed product variables to be written, got DeviceName %q", name)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// jsonSchema is the subset of JSON Schema (draft 4) used to describe the product variables.
+type jsonSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Type                 interface{}            `json:"type,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Minimum              *int                   `json:"minimum,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	AdditionalProperties interface{}            `json:"additionalProperties,omitempty"`
+}
+
+// ProductVariablesSchema returns a JSON Schema describing the product variables file.  The allowed
+// sanitizers are the ones registered when it is called.  Arch and cpu variants are not listed,
+// their names in product variables don't always match the registered property names.
+func ProductVariablesSchema() ([]byte, error) {
+	schema, err := structSchema(reflect.TypeOf(productVariables{}))
+	if err != nil {
+		return nil, err
+	}
+	schema.Schema = "http://json-schema.org/draft-04/schema#"
+	schema.Title = "Soong product variables"
+
+	enum := func(key string, values []string) {
+		if prop := schema.Properties[key]; prop != nil {
+			if prop.Items != nil {
+				prop = prop.Items
+			}
+			prop.Enum = values
+		}
+	}
+
+	arches := archNames()
+	for _, key := range []string{"DeviceArch", "DeviceSecondaryArch", "NativeBridgeArch",
+		"NativeBridgeSecondaryArch", "HostArch", "HostSecondaryArch", "CrossHostArch",
+		"CrossHostSecondaryArch", "SanitizeDeviceArch"} {
+		enum(key, arches)
+	}
+
+	if len(globalSanitizers) > 0 {
+		enum("SanitizeHost", globalSanitizers)
+		enum("SanitizeDevice", globalSanitizers)
+	}
+
+	var oses []string
+	for _, os := range osTypeList {
+		if os.Class == HostCross {
+			oses = append(oses, os.Name)
+		}
+	}
+	enum("CrossHost", oses)
+
+	one := 1
+	schema.Properties["Platform_sdk_version"].Minimum = &one
+
+	return json.MarshalIndent(schema, "", "    ")
+}
+
+func structSchema(t reflect.Type) (*jsonSchema, error) {
+	ret := &jsonSchema{
+		Type:                 "object",
+		Properties:           make(map[string]*jsonSchema),
+		AdditionalProperties: false,
+	}
+
+	for name, value := range jsonFields(reflect.New(t).Elem()) {
+		prop, err := typeSchema(value.Type())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err.Error())
+		}
+		ret.Properties[name] = prop
+	}
+
+	return ret, nil
+}
+
+func typeSchema(t reflect.Type) (*jsonSchema, error) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}, nil
+	case reflect.String:
+		return &jsonSchema{Type: "string"}, nil
+	case reflect.Int, reflect.Int64:
+		return &jsonSchema{Type: "integer"}, nil
+	case reflect.Slice:
+		items, err := typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &jsonSchema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		// Only Custom_variables is a map, its values are booleans or strings
+		if t.Key().Kind() != reflect.String || t.Elem().Kind() != reflect.Interface {
+			return nil, fmt.Errorf("unsupported map type %s", t)
+		}
+		return &jsonSchema{
+			Type:                 "object",
+			AdditionalProperties: &jsonSchema{Type: []string{"boolean", "string"}},
+		}, nil
+	case reflect.Struct:
+		return structSchema(t)
+	default:
+		return nil, fmt.Errorf("unsupported type %s", t)
+	}
+}
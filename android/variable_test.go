@@ -0,0 +1,249 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/google/blueprint"
+)
+
+var customProductVariablesTestCases = []struct {
+	variables map[string]interface{}
+	fields    []string
+	err       bool
+}{
+	{
+		variables: nil,
+	},
+	{
+		variables: map[string]interface{}{
+			"my_feature": true,
+			"my_board":   "foo",
+		},
+		fields: []string{"My_board", "My_feature"},
+	},
+
+	// Errors
+	{
+		variables: map[string]interface{}{"MyFeature": true},
+		err:       true,
+	},
+	{
+		variables: map[string]interface{}{"brillo": true},
+		err:       true,
+	},
+	{
+		variables: map[string]interface{}{"my_version": 3.0},
+		err:       true,
+	},
+}
+
+func TestCustomVariablePropertiesType(t *testing.T) {
+	for _, test := range customProductVariablesTestCases {
+		typ, err := newCustomVariablePropertiesType(test.variables)
+		if err != nil && !test.err {
+			t.Errorf("%v: unexpected error %s", test.variables, err.Error())
+			continue
+		} else if err == nil && test.err {
+			t.Errorf("%v: expected error", test.variables)
+			continue
+		} else if test.err {
+			continue
+		}
+
+		if test.fields == nil {
+			if typ != nil {
+				t.Errorf("%v: expected no properties, got %s", test.variables, typ)
+			}
+			continue
+		}
+
+		p := reflect.New(typ).Elem().FieldByName("Product_variables")
+		var fields []string
+		for i := 0; i < p.NumField(); i++ {
+			fields = append(fields, p.Type().Field(i).Name)
+		}
+		if !reflect.DeepEqual(fields, test.fields) {
+			t.Errorf("%v: expected fields %q, got %q", test.variables, test.fields, fields)
+		}
+	}
+}
+
+func TestCustomVariablesFactory(t *testing.T) {
+	buildDir, err := ioutil.TempDir("", "soong_variable_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(buildDir)
+
+	config := TestConfig(buildDir)
+	config.ProductVariables.Custom_variables = map[string]interface{}{
+		"my_feature": true,
+		"my_other":   false,
+		"my_board":   "foo",
+	}
+	config.customVariablePropertiesType, err = newCustomVariablePropertiesType(
+		config.ProductVariables.Custom_variables)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := NewContext()
+	ctx.RegisterModuleType("custom_variables",
+		customVariablesFactory(newCustomVariablesModule, config.customVariablePropertiesType))
+	ctx.RegisterModuleType("custom_variables_defaults",
+		customVariablesFactory(newCustomVariablesDefaultsModule, config.customVariablePropertiesType))
+	ctx.MockFileSystem(map[string][]byte{
+		"Blueprints": []byte(`
+			custom_variables_defaults {
+				name: "defaults",
+				product_variables: {
+					my_board: {
+						cflags: ["-DDEFAULTS_BOARD=%s"],
+					},
+				},
+			}
+
+			custom_variables {
+				name: "foo",
+				defaults: ["defaults"],
+				cflags: ["-DFOO"],
+				product_variables: {
+					my_feature: {
+						cflags: ["-DMY_FEATURE"],
+					},
+					my_other: {
+						cflags: ["-DMY_OTHER"],
+					},
+					my_board: {
+						cflags: ["-DBOARD=%s"],
+					},
+				},
+			}
+		`),
+	})
+
+	_, errs := ctx.ParseBlueprintsFiles("Blueprints")
+	fail(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+	fail(t, errs)
+
+	foo := findModule(ctx, "foo").(*customVariablesModule)
+	expected := []string{"-DFOO", "-DDEFAULTS_BOARD=foo", "-DBOARD=foo", "-DMY_FEATURE"}
+	if !reflect.DeepEqual(foo.properties.Cflags, expected) {
+		t.Errorf("expected cflags %q, got %q", expected, foo.properties.Cflags)
+	}
+}
+
+func TestCustomVariablePrintf(t *testing.T) {
+	props := customVariableProperties{
+		Cflags:  []string{"-DBOARD=%s", "-DFOO"},
+		Enabled: boolPtr(true),
+	}
+
+	v := nonZeroProperties(reflect.ValueOf(props))
+	if err := printfIntoProperties(v, "foo"); err != nil {
+		t.Fatal(err)
+	}
+
+	if v.NumField() != 2 {
+		t.Fatalf("expected 2 fields, got %d", v.NumField())
+	}
+
+	cflags := v.FieldByName("Cflags").Interface().([]string)
+	if expected := []string{"-DBOARD=foo", "-DFOO"}; !reflect.DeepEqual(cflags, expected) {
+		t.Errorf("expected cflags %q, got %q", expected, cflags)
+	}
+}
+
+var printfIntoPropertyTestCases = []struct {
+	in    string
+	value interface{}
+	out   string
+	err   bool
+}{
+	{in: "-DFOO=%s", value: "foo", out: "-DFOO=foo"},
+	{in: "-DFOO=%d", value: 3, out: "-DFOO=3"},
+	{in: "-DFOO=%d", value: true, out: "-DFOO=1"},
+	{in: "-DFOO", value: "foo", out: "-DFOO"},
+
+	// %s is left alone for values that are not strings
+	{in: "-DFOO=%s", value: true, out: "-DFOO=%s"},
+	{in: "-DFOO=%s", value: 3, out: "-DFOO=%s"},
+
+	// Errors
+	{in: "-DFOO=%d", value: "foo", err: true},
+	{in: "-DFOO=%d", value: 3.0, err: true},
+}
+
+func TestPrintfIntoProperty(t *testing.T) {
+	for _, test := range printfIntoPropertyTestCases {
+		s := test.in
+		err := printfIntoProperty(reflect.ValueOf(&s).Elem(), test.value)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q %v: expected error", test.in, test.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q %v: unexpected error %s", test.in, test.value, err.Error())
+		} else if s != test.out {
+			t.Errorf("%q %v: expected %q, got %q", test.in, test.value, test.out, s)
+		}
+	}
+}
+
+type customVariablesProperties struct {
+	Cflags []string `android:"arch_variant"`
+}
+
+type customVariablesModule struct {
+	ModuleBase
+	DefaultableModule
+	properties customVariablesProperties
+}
+
+func newCustomVariablesModule() (blueprint.Module, []interface{}) {
+	m := &customVariablesModule{}
+	_, props := InitAndroidArchModule(m, DeviceSupported, MultilibFirst, &m.properties)
+	return InitDefaultableModule(m, m, props...)
+}
+
+func (m *customVariablesModule) DepsMutator(ctx BottomUpMutatorContext) {
+}
+
+func (m *customVariablesModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+}
+
+type customVariablesDefaultsModule struct {
+	ModuleBase
+	DefaultsModule
+}
+
+func newCustomVariablesDefaultsModule() (blueprint.Module, []interface{}) {
+	m := &customVariablesDefaultsModule{}
+	return InitDefaultsModule(m, m, &customVariablesProperties{})
+}
+
+func (m *customVariablesDefaultsModule) DepsMutator(ctx BottomUpMutatorContext) {
+}
+
+func (m *customVariablesDefaultsModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+}
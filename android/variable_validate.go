@@ -0,0 +1,193 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// This file implements strict decoding and validation of the JSON configuration files.  Unlike
+// json.Decoder.Decode, keys must exactly match a field, so that a typo in a product variable is an
+// error instead of silently leaving the variable unset.
+
+var globalSanitizers []string
+
+// RegisterGlobalSanitizers registers the names that are valid in the SanitizeHost and
+// SanitizeDevice product variables.
+func RegisterGlobalSanitizers(names ...string) {
+	checkCalledFromInit()
+	globalSanitizers = append(globalSanitizers, names...)
+}
+
+// jsonFields returns the settable fields of a struct keyed by the name used in JSON.
+func jsonFields(v reflect.Value) map[string]reflect.Value {
+	ret := make(map[string]reflect.Value)
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			for name, value := range jsonFields(v.Field(i)) {
+				ret[name] = value
+			}
+			continue
+		}
+
+		name := field.Name
+		if tag := field.Tag.Get("json"); tag != "" {
+			if tag == "-" {
+				continue
+			}
+			if tagName := strings.Split(tag, ",")[0]; tagName != "" {
+				name = tagName
+			}
+		}
+		ret[name] = v.Field(i)
+	}
+	return ret
+}
+
+// decodeConfigStrict decodes a JSON object into configurable, which must be a pointer to a struct.
+// Every key in the object must exactly match the JSON name of a field.
+func decodeConfigStrict(filename string, data []byte, configurable interface{}) error {
+	var raw map[string]json.RawMessage
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return fmt.Errorf("%s did not parse correctly: %s", filename, err.Error())
+	}
+
+	fields := jsonFields(reflect.ValueOf(configurable).Elem())
+
+	var keys []string
+	for key := range raw {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		field, ok := fields[key]
+		if !ok {
+			for name := range fields {
+				if strings.EqualFold(name, key) {
+					return fmt.Errorf("%s: unknown key %q, did you mean %q?", filename, key, name)
+				}
+			}
+			return fmt.Errorf("%s: unknown key %q", filename, key)
+		}
+
+		err := json.Unmarshal(raw[key], field.Addr().Interface())
+		if err != nil {
+			return fmt.Errorf("%s: key %q: %s", filename, key, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// validateProductVariables checks that the values of the product variables are in range, and
+// returns an error for each invalid value.  Arch and cpu variants are checked against r.
+func validateProductVariables(r *archVariantRegistrations, v *productVariables) []error {
+	var errs []error
+	errorf := func(key, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("product variable %q: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	if v.Platform_sdk_version != nil && *v.Platform_sdk_version <= 0 {
+		errorf("Platform_sdk_version", "must be a positive integer, found %d", *v.Platform_sdk_version)
+	}
+
+	checkVariant := func(key string, archType ArchType, variant *string) {
+		if variant == nil || *variant == "" || *variant == "generic" || *variant == archType.Name {
+			return
+		}
+		// Arch variants are registered by cc/config, skip the check for tools that don't load it
+		if len(r.archVariants[archType]) > 0 &&
+			!inList(variantReplacer.Replace(*variant), r.archVariants[archType]) {
+			errorf(key, "unknown variant %q for arch %q", *variant, archType.Name)
+		}
+	}
+
+	arches := []struct {
+		archKey, archVariantKey, cpuVariantKey string
+		arch, archVariant, cpuVariant          *string
+	}{
+		{"DeviceArch", "DeviceArchVariant", "DeviceCpuVariant",
+			v.DeviceArch, v.DeviceArchVariant, v.DeviceCpuVariant},
+		{"DeviceSecondaryArch", "DeviceSecondaryArchVariant", "DeviceSecondaryCpuVariant",
+			v.DeviceSecondaryArch, v.DeviceSecondaryArchVariant, v.DeviceSecondaryCpuVariant},
+		{"NativeBridgeArch", "NativeBridgeArchVariant", "NativeBridgeCpuVariant",
+			v.NativeBridgeArch, v.NativeBridgeArchVariant, v.NativeBridgeCpuVariant},
+		{"NativeBridgeSecondaryArch", "NativeBridgeSecondaryArchVariant", "NativeBridgeSecondaryCpuVariant",
+			v.NativeBridgeSecondaryArch, v.NativeBridgeSecondaryArchVariant, v.NativeBridgeSecondaryCpuVariant},
+		{archKey: "HostArch", arch: v.HostArch},
+		{archKey: "HostSecondaryArch", arch: v.HostSecondaryArch},
+		{archKey: "CrossHostArch", arch: v.CrossHostArch},
+		{archKey: "CrossHostSecondaryArch", arch: v.CrossHostSecondaryArch},
+	}
+
+	for _, a := range arches {
+		if a.arch == nil || *a.arch == "" {
+			continue
+		}
+		archType, ok := archTypeMap[*a.arch]
+		if !ok {
+			errorf(a.archKey, "unknown arch %q, valid arches are %s", *a.arch, strings.Join(archNames(), ", "))
+			continue
+		}
+		checkVariant(a.archVariantKey, archType, a.archVariant)
+		checkVariant(a.cpuVariantKey, archType, a.cpuVariant)
+	}
+
+	if v.CrossHost != nil && *v.CrossHost != "" && osByName(*v.CrossHost) == NoOsType {
+		errorf("CrossHost", "unknown os %q", *v.CrossHost)
+	}
+
+	for _, arch := range v.SanitizeDeviceArch {
+		if _, ok := archTypeMap[arch]; !ok {
+			errorf("SanitizeDeviceArch", "unknown arch %q", arch)
+		}
+	}
+
+	checkSanitizers := func(key string, sanitizers []string) {
+		// Sanitizers are registered by cc, skip the check for tools that don't load it
+		if len(globalSanitizers) == 0 {
+			return
+		}
+		for _, sanitizer := range sanitizers {
+			if !inList(sanitizer, globalSanitizers) {
+				errorf(key, "unknown sanitizer %q, valid sanitizers are %s", sanitizer,
+					strings.Join(globalSanitizers, ", "))
+			}
+		}
+	}
+	checkSanitizers("SanitizeHost", v.SanitizeHost)
+	checkSanitizers("SanitizeDevice", v.SanitizeDevice)
+
+	return errs
+}
+
+func archNames() []string {
+	var ret []string
+	for _, archType := range archTypeList {
+		ret = append(ret, archType.Name)
+	}
+	return ret
+}
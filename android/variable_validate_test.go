@@ -0,0 +1,165 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+var decodeConfigStrictTestCases = []struct {
+	in  string
+	err string
+}{
+	{
+		in: `{"DeviceName": "foo", "Safestack": true, "Custom_variables": {"anything": true}}`,
+	},
+	{
+		in:  `{"Safestak": true}`,
+		err: `soong.variables: unknown key "Safestak"`,
+	},
+	{
+		in:  `{"safestack": true}`,
+		err: `soong.variables: unknown key "safestack", did you mean "Safestack"?`,
+	},
+	{
+		in:  `{"Safestack": "yes"}`,
+		err: `soong.variables: key "Safestack": json: cannot unmarshal string`,
+	},
+	{
+		in:  `["Safestack"]`,
+		err: `soong.variables did not parse correctly`,
+	},
+}
+
+func TestDecodeConfigStrict(t *testing.T) {
+	for _, test := range decodeConfigStrictTestCases {
+		var variables productVariables
+		err := decodeConfigStrict("soong.variables", []byte(test.in), &variables)
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %s", test.in, err.Error())
+			}
+		} else if err == nil {
+			t.Errorf("%s: expected error %q", test.in, test.err)
+		} else if !strings.HasPrefix(err.Error(), test.err) {
+			t.Errorf("%s: expected error %q, got %q", test.in, test.err, err.Error())
+		}
+	}
+}
+
+var validateProductVariablesTestCases = []struct {
+	in   string
+	errs []string
+}{
+	{
+		in: `{
+			"DeviceArch": "arm64",
+			"DeviceArchVariant": "armv8-a",
+			"DeviceCpuVariant": "cortex-a53",
+			"DeviceSecondaryArch": "arm",
+			"DeviceSecondaryCpuVariant": "generic",
+			"HostArch": "x86_64",
+			"CrossHost": "windows",
+			"SanitizeDevice": ["address"],
+			"SanitizeDeviceArch": ["arm64"]
+		}`,
+	},
+	{
+		in: `{
+			"DeviceArch": "arm65",
+			"HostArch": "x86_64",
+			"DeviceSecondaryArch": "arm",
+			"DeviceSecondaryCpuVariant": "cortex-a99",
+			"CrossHost": "plan9",
+			"SanitizeHost": ["adress"],
+			"SanitizeDeviceArch": ["arm65"],
+			"Platform_sdk_version": 0
+		}`,
+		errs: []string{
+			`product variable "Platform_sdk_version": must be a positive integer, found 0`,
+			`product variable "DeviceArch": unknown arch "arm65"`,
+			`product variable "DeviceSecondaryCpuVariant": unknown variant "cortex-a99" for arch "arm"`,
+			`product variable "CrossHost": unknown os "plan9"`,
+			`product variable "SanitizeDeviceArch": unknown arch "arm65"`,
+			`product variable "SanitizeHost": unknown sanitizer "adress"`,
+		},
+	},
+}
+
+func TestValidateProductVariables(t *testing.T) {
+	oldArchVariants, oldGlobalSanitizers := archVariants, globalSanitizers
+	defer func() {
+		archVariants, globalSanitizers = oldArchVariants, oldGlobalSanitizers
+	}()
+
+	archVariants = map[ArchType][]string{
+		Arm:   {"armv7_a_neon", "cortex_a53"},
+		Arm64: {"armv8_a", "cortex_a53"},
+	}
+	globalSanitizers = []string{"address", "thread"}
+
+	for _, test := range validateProductVariablesTestCases {
+		var variables productVariables
+		if err := json.Unmarshal([]byte(test.in), &variables); err != nil {
+			t.Fatal(err)
+		}
+
+		errs := validateProductVariables(newArchVariantRegistrations(), &variables)
+		if len(errs) != len(test.errs) {
+			t.Errorf("%s: expected %d errors, got %q", test.in, len(test.errs), errs)
+			continue
+		}
+		for i := range errs {
+			if !strings.HasPrefix(errs[i].Error(), test.errs[i]) {
+				t.Errorf("%s: expected error %q, got %q", test.in, test.errs[i], errs[i].Error())
+			}
+		}
+	}
+}
+
+func TestProductVariablesSchema(t *testing.T) {
+	data, err := ProductVariablesSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var schema jsonSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	if schema.AdditionalProperties != false {
+		t.Errorf("expected additionalProperties false, got %v", schema.AdditionalProperties)
+	}
+
+	for key, typ := range map[string]string{
+		"Safestack":            "boolean",
+		"DeviceName":           "string",
+		"Platform_sdk_version": "integer",
+		"DeviceAbi":            "array",
+		"SanitizeDevice":       "array",
+		"Custom_variables":     "object",
+	} {
+		if prop := schema.Properties[key]; prop == nil || prop.Type != typ {
+			t.Errorf("expected %q to have type %q, got %v", key, typ, prop)
+		}
+	}
+
+	if enum := schema.Properties["DeviceArch"].Enum; !inList("arm64", enum) {
+		t.Errorf("expected DeviceArch enum to contain arm64, got %q", enum)
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/blueprint"
+)
+
+// This file implements the visibility property, which restricts the Blueprint directories whose
+// modules are allowed to depend on a module.  Each entry in the list is one of:
+//
+//   //visibility:public         any module may depend on this module (the default)
+//   //visibility:private        only modules in the same directory may depend on this module
+//   //some/dir:__pkg__          modules in some/dir may depend on this module
+//   //some/dir:__subpackages__  modules in some/dir or any directory below it may depend on
+//                               this module
+//   //some/dir/...              same as //some/dir:__subpackages__
+//   :__pkg__                    same as //<module dir>:__pkg__
+//   :__subpackages__            same as //<module dir>:__subpackages__
+//
+// Modules in the same directory as a module can always depend on it.
+
+type visibilityRule struct {
+	public      bool
+	dir         string
+	subpackages bool
+}
+
+func (r visibilityRule) matches(dir string) bool {
+	if r.public {
+		return true
+	}
+	if dir == r.dir {
+		return true
+	}
+	if r.subpackages {
+		return r.dir == "." || strings.HasPrefix(dir, r.dir+"/")
+	}
+	return false
+}
+
+// parseVisibilityRule converts a single entry of the visibility property of a module in moduleDir
+// into a visibilityRule.
+func parseVisibilityRule(moduleDir, rule string) (visibilityRule, error) {
+	switch rule {
+	case "//visibility:public":
+		return visibilityRule{public: true}, nil
+	case "//visibility:private":
+		return visibilityRule{dir: moduleDir}, nil
+	}
+
+	var dir, target string
+	if strings.HasPrefix(rule, "//") {
+		dir = strings.TrimPrefix(rule, "//")
+		if i := strings.LastIndex(dir, ":"); i != -1 {
+			dir, target = dir[:i], dir[i+1:]
+		} else if dir == "..." || strings.HasSuffix(dir, "/...") {
+			dir, target = strings.TrimSuffix(strings.TrimSuffix(dir, "..."), "/"), "__subpackages__"
+		} else {
+			return visibilityRule{}, fmt.Errorf("%q must end in :__pkg__, :__subpackages__ or /...", rule)
+		}
+	} else if strings.HasPrefix(rule, ":") {
+		dir, target = moduleDir, rule[1:]
+	} else {
+		return visibilityRule{}, fmt.Errorf("%q must start with // or :", rule)
+	}
+
+	if dir == "" {
+		dir = "."
+	}
+	if filepath.Clean(dir) != dir || strings.HasPrefix(dir, "../") || dir == ".." {
+		return visibilityRule{}, fmt.Errorf("%q is not a clean directory path", rule)
+	}
+	if dir == "visibility" {
+		return visibilityRule{}, fmt.Errorf("unknown visibility rule %q", rule)
+	}
+
+	switch target {
+	case "__pkg__":
+		return visibilityRule{dir: dir}, nil
+	case "__subpackages__":
+		return visibilityRule{dir: dir, subpackages: true}, nil
+	default:
+		return visibilityRule{}, fmt.Errorf("%q must end in :__pkg__ or :__subpackages__", rule)
+	}
+}
+
+// parseVisibility converts the visibility property of a module in moduleDir into a list of rules.
+func parseVisibility(moduleDir string, visibility []string) ([]visibilityRule, []error) {
+	var rules []visibilityRule
+	var errs []error
+	for _, v := range visibility {
+		rule, err := parseVisibilityRule(moduleDir, v)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if rule.public && len(visibility) > 1 {
+			errs = append(errs, fmt.Errorf("//visibility:public cannot be combined with other rules"))
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules, errs
+}
+
+// isVisibleTo returns true if a module in moduleDir with the given visibility rules may be
+// depended on by a module in dir.
+func isVisibleTo(moduleDir string, rules []visibilityRule, dir string) bool {
+	if len(rules) == 0 || moduleDir == dir {
+		return true
+	}
+
+	for _, rule := range rules {
+		if rule.matches(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// visibilityMutator runs after depsMutator has added all dependencies.  It reports an error for an
+// invalid visibility property, and for every direct dependency on a module that is not visible to
+// the depending module's directory.  To report each error once instead of once per variant, only
+// the first variant of each module is checked.
+func visibilityMutator(ctx BottomUpMutatorContext) {
+	m, ok := ctx.Module().(Module)
+	if !ok {
+		return
+	}
+
+	base := m.base()
+	base.commonProperties.ModuleDir = ctx.ModuleDir()
+
+	if base.commonProperties.NotFirstVariant {
+		return
+	}
+
+	_, errs := parseVisibility(ctx.ModuleDir(), base.commonProperties.Visibility)
+	for _, err := range errs {
+		ctx.PropertyErrorf("visibility", "%s", err.Error())
+	}
+
+	ctx.VisitDirectDeps(func(dep blueprint.Module) {
+		if ctx.OtherModuleDependencyTag(dep) == prebuiltDependencyTag {
+			// Added by prebuiltMutator from the source module to its prebuilt, not by the user
+			return
+		}
+
+		d, ok := dep.(Module)
+		if !ok {
+			return
+		}
+
+		depProps := &d.base().commonProperties
+		rules, errs := parseVisibility(depProps.ModuleDir, depProps.Visibility)
+		if len(errs) > 0 {
+			// The dependency reports its own invalid visibility property
+			return
+		}
+		if !isVisibleTo(depProps.ModuleDir, rules, ctx.ModuleDir()) {
+			ctx.ModuleErrorf("depends on %q in %q, which is not visible to modules in %q",
+				ctx.OtherModuleName(dep), depProps.ModuleDir, ctx.ModuleDir())
+		}
+	})
+}
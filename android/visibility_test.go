@@ -0,0 +1,238 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/blueprint"
+)
+
+var visibilityTestCases = []struct {
+	moduleDir  string
+	visibility []string
+	dir        string
+	visible    bool
+}{
+	{
+		moduleDir: "frameworks/base",
+		dir:       "vendor/foo",
+		visible:   true,
+	},
+	{
+		moduleDir:  "frameworks/base",
+		visibility: []string{"//visibility:public"},
+		dir:        "vendor/foo",
+		visible:    true,
+	},
+	{
+		moduleDir:  "frameworks/base",
+		visibility: []string{"//visibility:private"},
+		dir:        "frameworks/base",
+		visible:    true,
+	},
+	{
+		moduleDir:  "frameworks/base",
+		visibility: []string{"//visibility:private"},
+		dir:        "frameworks/base/core",
+		visible:    false,
+	},
+	{
+		moduleDir:  "frameworks/base",
+		visibility: []string{":__subpackages__"},
+		dir:        "frameworks/base/core",
+		visible:    true,
+	},
+	{
+		moduleDir:  "frameworks/base",
+		visibility: []string{":__subpackages__"},
+		dir:        "frameworks/baseline",
+		visible:    false,
+	},
+	{
+		moduleDir:  "system/core/libfoo",
+		visibility: []string{"//frameworks/base/..."},
+		dir:        "frameworks/base/core/jni",
+		visible:    true,
+	},
+	{
+		moduleDir:  "system/core/libfoo",
+		visibility: []string{"//frameworks/base:__pkg__"},
+		dir:        "frameworks/base/core/jni",
+		visible:    false,
+	},
+	{
+		moduleDir:  "system/core/libfoo",
+		visibility: []string{"//frameworks/base:__pkg__", "//system/core:__subpackages__"},
+		dir:        "system/core/libbar",
+		visible:    true,
+	},
+	{
+		moduleDir:  "system/core/libfoo",
+		visibility: []string{"//..."},
+		dir:        "vendor/foo",
+		visible:    true,
+	},
+}
+
+func TestIsVisibleTo(t *testing.T) {
+	for _, test := range visibilityTestCases {
+		rules, errs := parseVisibility(test.moduleDir, test.visibility)
+		if len(errs) > 0 {
+			t.Errorf("%q: unexpected errors %q", test.visibility, errs)
+			continue
+		}
+		got := isVisibleTo(test.moduleDir, rules, test.dir)
+		if got != test.visible {
+			t.Errorf("%q in %q visible to %q: expected %v, got %v",
+				test.visibility, test.moduleDir, test.dir, test.visible, got)
+		}
+	}
+}
+
+var badVisibilityTestCases = [][]string{
+	{"frameworks/base:__pkg__"},
+	{"//frameworks/base"},
+	{"//frameworks/base:foo"},
+	{"//frameworks/../base:__pkg__"},
+	{"//visibility:unknown"},
+	{"//visibility:public", "//frameworks/base:__pkg__"},
+}
+
+func TestBadVisibility(t *testing.T) {
+	for _, visibility := range badVisibilityTestCases {
+		_, errs := parseVisibility("system/core", visibility)
+		if len(errs) == 0 {
+			t.Errorf("%q: expected error", visibility)
+		}
+	}
+}
+
+var visibilityMutatorTestCases = []struct {
+	name string
+	a, b string
+	err  string
+}{
+	{
+		name: "visible",
+		a: `
+			visibility_test {
+				name: "foo",
+				visibility: ["//b:__pkg__"],
+			}
+		`,
+		b: `
+			visibility_test {
+				name: "bar",
+				deps: ["foo"],
+			}
+		`,
+	},
+	{
+		name: "not visible",
+		a: `
+			visibility_test {
+				name: "foo",
+				visibility: ["//visibility:private"],
+			}
+		`,
+		b: `
+			visibility_test {
+				name: "bar",
+				deps: ["foo"],
+			}
+		`,
+		err: `depends on "foo" in "a", which is not visible to modules in "b"`,
+	},
+	{
+		// Only the invalid property is reported, not the dependency on foo
+		name: "invalid",
+		a: `
+			visibility_test {
+				name: "foo",
+				visibility: ["//b"],
+			}
+		`,
+		b: `
+			visibility_test {
+				name: "bar",
+				deps: ["foo"],
+			}
+		`,
+		err: `"//b" must end in :__pkg__, :__subpackages__ or /...`,
+	},
+}
+
+func TestVisibilityMutator(t *testing.T) {
+	buildDir, err := ioutil.TempDir("", "soong_visibility_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(buildDir)
+
+	for _, test := range visibilityMutatorTestCases {
+		// Two variants of each module, so errors reported for every variant would be duplicated
+		config := TestConfig(buildDir)
+		config.Targets = map[OsClass][]Target{
+			Device: {
+				{Os: Android, Arch: Arch{ArchType: Arm64}},
+				{Os: Android, Arch: Arch{ArchType: Arm}},
+			},
+		}
+
+		ctx := NewContext()
+		ctx.RegisterModuleType("visibility_test", newVisibilityModule)
+		ctx.MockFileSystem(map[string][]byte{
+			"Blueprints":   []byte(`subdirs = ["a", "b"]`),
+			"a/Blueprints": []byte(test.a),
+			"b/Blueprints": []byte(test.b),
+		})
+
+		_, errs := ctx.ParseBlueprintsFiles("Blueprints")
+		if len(errs) == 0 {
+			_, errs = ctx.PrepareBuildActions(config)
+		}
+
+		if test.err == "" {
+			for _, err := range errs {
+				t.Errorf("%s: unexpected error %s", test.name, err.Error())
+			}
+		} else if len(errs) != 1 || !strings.Contains(errs[0].Error(), test.err) {
+			t.Errorf("%s: expected one error containing %q, got %q", test.name, test.err, errs)
+		}
+	}
+}
+
+type visibilityModule struct {
+	ModuleBase
+	properties struct {
+		Deps []string
+	}
+}
+
+func newVisibilityModule() (blueprint.Module, []interface{}) {
+	m := &visibilityModule{}
+	return InitAndroidArchModule(m, DeviceSupported, MultilibBoth, &m.properties)
+}
+
+func (m *visibilityModule) DepsMutator(ctx BottomUpMutatorContext) {
+	ctx.AddDependency(ctx.Module(), nil, m.properties.Deps...)
+}
+
+func (m *visibilityModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+}
@@ -0,0 +1,150 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/google/blueprint/proptools"
+)
+
+// This file implements warnings, which are printed to stderr and written to the diagnostics file
+// but don't fail the build, unless the module is in one of the directories listed in the
+// WarningsAsErrorsDirs product variable.
+//
+// It also implements deprecated properties.  A property struct field can be marked as deprecated
+// with a tag like `android:"deprecated=use local_include_dirs"`, and setting it in a Blueprints
+// file causes a warning that includes the text after the '='.  The text cannot contain commas.
+
+type warningContext interface {
+	diagnosticContext
+	ModuleDir() string
+	ModuleErrorf(format string, args ...interface{})
+	PropertyErrorf(property, format string, args ...interface{})
+}
+
+func warningf(ctx warningContext, target Target, property, format string, args ...interface{}) {
+	if ctx.Config().(Config).WarningsAsErrors(ctx.ModuleDir()) {
+		if property == "" {
+			ctx.ModuleErrorf(format, args...)
+		} else {
+			ctx.PropertyErrorf(property, format, args...)
+		}
+		return
+	}
+
+	msg := fmt.Sprintf(format, args...)
+	if property != "" {
+		fmt.Fprintf(os.Stderr, "%s: warning: module %q: %s: %s\n", ctx.BlueprintsFile(),
+			ctx.ModuleName(), property, msg)
+	} else {
+		fmt.Fprintf(os.Stderr, "%s: warning: module %q: %s\n", ctx.BlueprintsFile(),
+			ctx.ModuleName(), msg)
+	}
+
+	reportDiagnostic(ctx, target, property, SeverityWarning, msg)
+}
+
+func (a *androidModuleContext) ModuleWarningf(format string, args ...interface{}) {
+	warningf(a, a.target, "", format, args...)
+}
+
+func (a *androidModuleContext) PropertyWarningf(property, format string, args ...interface{}) {
+	warningf(a, a.target, property, format, args...)
+}
+
+func (a *androidBottomUpMutatorContext) ModuleWarningf(format string, args ...interface{}) {
+	warningf(a, a.target, "", format, args...)
+}
+
+func (a *androidBottomUpMutatorContext) PropertyWarningf(property, format string, args ...interface{}) {
+	warningf(a, a.target, property, format, args...)
+}
+
+func (a *androidTopDownMutatorContext) ModuleWarningf(format string, args ...interface{}) {
+	warningf(a, a.target, "", format, args...)
+}
+
+func (a *androidTopDownMutatorContext) PropertyWarningf(property, format string, args ...interface{}) {
+	warningf(a, a.target, property, format, args...)
+}
+
+// deprecatedMessage returns the text of the deprecated= entry in the android tag of a field.
+func deprecatedMessage(field reflect.StructField) (string, bool) {
+	for _, entry := range strings.Split(field.Tag.Get("android"), ",") {
+		if strings.HasPrefix(entry, "deprecated=") {
+			return strings.TrimPrefix(entry, "deprecated="), true
+		}
+	}
+	return "", false
+}
+
+// deprecatedProperties returns the names of the deprecated properties that are set in a property
+// struct, along with their deprecation messages.
+func deprecatedProperties(prefix string, v reflect.Value) (names, messages []string) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		fieldValue := v.Field(i)
+		name := prefix + proptools.PropertyNameForField(field.Name)
+
+		if message, ok := deprecatedMessage(field); ok {
+			if !reflect.DeepEqual(fieldValue.Interface(), reflect.Zero(field.Type).Interface()) {
+				names = append(names, name)
+				messages = append(messages, message)
+			}
+			continue
+		}
+
+		if fieldValue.Kind() == reflect.Ptr && !fieldValue.IsNil() {
+			fieldValue = fieldValue.Elem()
+		}
+		if fieldValue.Kind() == reflect.Struct {
+			n, m := deprecatedProperties(name+".", fieldValue)
+			names = append(names, n...)
+			messages = append(messages, m...)
+		}
+	}
+	return names, messages
+}
+
+// deprecatedPropertiesMutator runs after the load hooks and before defaults are applied, so that
+// only the module that sets a deprecated property warns about it.
+func deprecatedPropertiesMutator(ctx BottomUpMutatorContext) {
+	m, ok := ctx.Module().(Module)
+	if !ok {
+		return
+	}
+
+	base := m.base()
+	seen := make(map[interface{}]bool)
+	for _, props := range append(base.propertyStructs, base.generalProperties...) {
+		if seen[props] {
+			continue
+		}
+		seen[props] = true
+
+		names, messages := deprecatedProperties("", reflect.ValueOf(props).Elem())
+		for i := range names {
+			ctx.PropertyWarningf(names[i], "property is deprecated: %s", messages[i])
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"reflect"
+	"testing"
+)
+
+type deprecatedTestProperties struct {
+	Include_dirs []string `android:"arch_variant,deprecated=use local_include_dirs"`
+	Srcs         []string
+
+	Nested struct {
+		Old_flag *bool `android:"deprecated=has no effect"`
+		New_flag *bool
+	}
+}
+
+func TestDeprecatedProperties(t *testing.T) {
+	testCases := []struct {
+		props    deprecatedTestProperties
+		names    []string
+		messages []string
+	}{
+		{
+			props: deprecatedTestProperties{
+				Srcs: []string{"a.c"},
+			},
+		},
+		{
+			props: deprecatedTestProperties{
+				Include_dirs: []string{"include"},
+			},
+			names:    []string{"include_dirs"},
+			messages: []string{"use local_include_dirs"},
+		},
+		{
+			props: func() deprecatedTestProperties {
+				p := deprecatedTestProperties{
+					Include_dirs: []string{"include"},
+				}
+				p.Nested.Old_flag = boolPtr(false)
+				p.Nested.New_flag = boolPtr(true)
+				return p
+			}(),
+			names:    []string{"include_dirs", "nested.old_flag"},
+			messages: []string{"use local_include_dirs", "has no effect"},
+		},
+	}
+
+	for _, test := range testCases {
+		names, messages := deprecatedProperties("", reflect.ValueOf(&test.props).Elem())
+		if !reflect.DeepEqual(names, test.names) || !reflect.DeepEqual(messages, test.messages) {
+			t.Errorf("%+v: expected %q %q, got %q %q", test.props, test.names, test.messages,
+				names, messages)
+		}
+	}
+}
+
+func TestWarningsAsErrors(t *testing.T) {
+	config := TestConfig("out")
+	config.ProductVariables.WarningsAsErrorsDirs = []string{"frameworks/base", "external/foo/"}
+
+	testCases := []struct {
+		dir string
+		out bool
+	}{
+		{"frameworks/base", true},
+		{"frameworks/base/core", true},
+		{"frameworks/basement", false},
+		{"external/foo", true},
+		{"external", false},
+	}
+
+	for _, test := range testCases {
+		if got := config.WarningsAsErrors(test.dir); got != test.out {
+			t.Errorf("%q: expected %t, got %t", test.dir, test.out, got)
+		}
+	}
+}
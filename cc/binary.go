@@ -168,6 +168,12 @@ func (binary *binaryDecorator) linkerInit(ctx BaseModuleContext) {
 			if binary.Properties.Static_executable == nil && Bool(ctx.AConfig().ProductVariables.HostStaticBinaries) {
 				binary.Properties.Static_executable = proptools.BoolPtr(true)
 			}
+		} else if ctx.Os() == android.LinuxMusl {
+			// musl is used for host binaries that must run without a compatible libc on the
+			// host, link them statically unless the module says otherwise
+			if binary.Properties.Static_executable == nil {
+				binary.Properties.Static_executable = proptools.BoolPtr(true)
+			}
 		} else {
 			// Static executables are not supported on Darwin or Windows
 			binary.Properties.Static_executable = nil
@@ -613,6 +613,19 @@ func CopyGccLib(ctx android.ModuleContext, libName string,
 }
 
 func gccCmd(toolchain config.Toolchain, cmd string) string {
+	if !toolchain.GccSupported() {
+		// Use the clang and LLVM equivalents of the GCC tools
+		switch cmd {
+		case "gcc":
+			return "${config.ClangBin}/clang"
+		case "g++":
+			return "${config.ClangBin}/clang++"
+		case "ld":
+			return "${config.ClangBin}/ld.lld"
+		default:
+			return "${config.ClangBin}/llvm-" + cmd
+		}
+	}
 	return filepath.Join(toolchain.GccRoot(), "bin", toolchain.GccTriple()+"-"+cmd)
 }
 
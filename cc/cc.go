@@ -19,6 +19,7 @@ package cc
 // is handled in builder.go
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
 
@@ -142,8 +143,8 @@ type BaseProperties struct {
 }
 
 type UnusedProperties struct {
-	Native_coverage *bool
-	Tags            []string
+	Native_coverage *bool    `android:"deprecated=has no effect"`
+	Tags            []string `android:"deprecated=has no effect"`
 }
 
 type ModuleContextIntf interface {
@@ -212,6 +213,10 @@ type dependencyTag struct {
 	reexportFlags bool
 }
 
+func (t dependencyTag) String() string {
+	return t.name
+}
+
 var (
 	sharedDepTag          = dependencyTag{name: "shared", library: true}
 	sharedExportDepTag    = dependencyTag{name: "shared", library: true, reexportFlags: true}
@@ -717,6 +722,10 @@ func (c *Module) clang(ctx BaseModuleContext) bool {
 		clang = false
 	}
 
+	if !c.toolchain(ctx).GccSupported() {
+		clang = true
+	}
+
 	return clang
 }
 
@@ -795,6 +804,11 @@ func (c *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 		name := ctx.OtherModuleName(m)
 		tag := ctx.OtherModuleDependencyTag(m)
 
+		if tag == android.SourceDepTag {
+			// Output files of modules referenced in srcs are handled by ExpandSources
+			return
+		}
+
 		a, _ := m.(android.Module)
 		if a == nil {
 			ctx.ModuleErrorf("module %q not an android module", name)
@@ -807,6 +821,13 @@ func (c *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 			case android.DefaultsDepTag:
 			case genSourceDepTag:
 				if genRule, ok := m.(genrule.SourceFileGenerator); ok {
+					// The files written by an out_dir genrule are not known until it has run,
+					// so there is no way to create a compile rule for each of them.
+					if dirGen, ok := m.(genrule.SourceDirGenerator); ok && dirGen.GeneratedFileList().Valid() {
+						ctx.PropertyErrorf("generated_sources",
+							"module %q uses out_dir, which is only supported in generated_headers", name)
+						return
+					}
 					depPaths.GeneratedSources = append(depPaths.GeneratedSources,
 						genRule.GeneratedSourceFiles()...)
 				} else {
@@ -950,6 +971,37 @@ func (c *Module) HostToolPath() android.OptionalPath {
 	return c.installer.hostToolPath()
 }
 
+var _ android.OutputFileProducer = (*Module)(nil)
+
+func (c *Module) OutputFiles(tag string) (android.Paths, error) {
+	if tag != "" {
+		return nil, fmt.Errorf("unsupported tag %q", tag)
+	}
+	if !c.outputFile.Valid() {
+		return nil, fmt.Errorf("module has no output file")
+	}
+	return android.Paths{c.outputFile.Path()}, nil
+}
+
+var _ android.ExportedIncludeDirsProducer = (*Module)(nil)
+
+// ExportedIncludeDirs returns the include directories in the flags exported by the module.
+func (c *Module) ExportedIncludeDirs() []string {
+	i, ok := c.linker.(exportedFlagsProducer)
+	if !ok {
+		return nil
+	}
+	var dirs []string
+	for _, flag := range i.exportedFlags() {
+		if strings.HasPrefix(flag, "-isystem") {
+			dirs = append(dirs, strings.TrimPrefix(flag, "-isystem"))
+		} else if strings.HasPrefix(flag, "-I") {
+			dirs = append(dirs, strings.TrimPrefix(flag, "-I"))
+		}
+	}
+	return dirs
+}
+
 //
 // Defaults
 //
@@ -3,6 +3,9 @@ package cc
 import (
 	"reflect"
 	"testing"
+
+	"android/soong/android"
+	"android/soong/cc/config"
 )
 
 var lastUniqueElementsTestCases = []struct {
@@ -152,3 +155,103 @@ func TestSplitListForSize(t *testing.T) {
 		}
 	}
 }
+
+func TestToolchainLinker(t *testing.T) {
+	riscv64 := config.FindToolchain(android.Android, android.Arch{
+		ArchType:    android.Riscv64,
+		ArchVariant: "rv64gc",
+	})
+	arm64 := config.FindToolchain(android.Android, android.Arch{
+		ArchType:    android.Arm64,
+		ArchVariant: "armv8-a",
+	})
+
+	testCases := []struct {
+		name      string
+		toolchain config.Toolchain
+		ldflags   []string
+		ld        string
+		ar        string
+	}{
+		{
+			name:      "riscv64",
+			toolchain: riscv64,
+			ldflags:   []string{"-fuse-ld=lld"},
+			ld:        "${config.ClangBin}/ld.lld",
+			ar:        "${config.ClangBin}/llvm-ar",
+		},
+		{
+			name:      "arm64",
+			toolchain: arm64,
+			ldflags:   nil,
+			ld:        "${config.Arm64GccRoot}/bin/aarch64-linux-android-ld",
+			ar:        "${config.Arm64GccRoot}/bin/aarch64-linux-android-ar",
+		},
+	}
+
+	for _, testCase := range testCases {
+		if ldflags := clangLinkerFlags(testCase.toolchain); !reflect.DeepEqual(ldflags, testCase.ldflags) {
+			t.Errorf("%s: expected ldflags %q, got %q", testCase.name, testCase.ldflags, ldflags)
+		}
+		if ld := gccCmd(testCase.toolchain, "ld"); ld != testCase.ld {
+			t.Errorf("%s: expected ld %q, got %q", testCase.name, testCase.ld, ld)
+		}
+		if ar := gccCmd(testCase.toolchain, "ar"); ar != testCase.ar {
+			t.Errorf("%s: expected ar %q, got %q", testCase.name, testCase.ar, ar)
+		}
+	}
+}
+
+func TestTargetSubDir(t *testing.T) {
+	testCases := []struct {
+		name     string
+		target   android.Target
+		expected string
+	}{
+		{
+			name:     "native device arch",
+			target:   android.Target{Os: android.Android, Arch: android.Arch{ArchType: android.X86_64, Native: true}},
+			expected: "lib64",
+		},
+		{
+			name:     "secondary device arch",
+			target:   android.Target{Os: android.Android, Arch: android.Arch{ArchType: android.Arm}},
+			expected: "lib/arm",
+		},
+		{
+			name:     "host arch",
+			target:   android.Target{Os: android.Linux, Arch: android.Arch{ArchType: android.X86}},
+			expected: "lib",
+		},
+		{
+			name: "native bridge",
+			target: android.Target{
+				Os:                       android.Android,
+				Arch:                     android.Arch{ArchType: android.Arm},
+				NativeBridge:             true,
+				NativeBridgeRelativePath: "arm",
+			},
+			expected: "lib/arm",
+		},
+		{
+			name: "native bridge relative path",
+			target: android.Target{
+				Os:                       android.Android,
+				Arch:                     android.Arch{ArchType: android.Arm64},
+				NativeBridge:             true,
+				NativeBridgeRelativePath: "arm64/nb",
+			},
+			expected: "lib64/arm64/nb",
+		},
+	}
+
+	for _, testCase := range testCases {
+		dir := "lib"
+		if testCase.target.Arch.ArchType.Multilib == "lib64" {
+			dir = "lib64"
+		}
+		if got := targetSubDir(dir, testCase.target); got != testCase.expected {
+			t.Errorf("%s: expected %q, got %q", testCase.name, testCase.expected, got)
+		}
+	}
+}
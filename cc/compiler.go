@@ -29,11 +29,12 @@ import (
 
 type BaseCompilerProperties struct {
 	// list of source files used to compile the C/C++ module.  May be .c, .cpp, or .S files.
-	Srcs []string `android:"arch_variant"`
+	// Use ":module_name" or ":module_name{.tag}" to compile the output files of another module.
+	Srcs []string `android:"arch_variant,path"`
 
 	// list of source files that should not be used to build the C/C++ module.
 	// This is most useful in the arch/multilib variants to remove non-common files
-	Exclude_srcs []string `android:"arch_variant"`
+	Exclude_srcs []string `android:"arch_variant,path"`
 
 	// list of module-specific flags that will be used for C and C++ compiles.
 	Cflags []string `android:"arch_variant"`
@@ -230,13 +231,15 @@ func (compiler *baseCompiler) compilerFlags(ctx ModuleContext, flags Flags) Flag
 
 		target := "-target " + tc.ClangTriple()
 		var gccPrefix string
-		if !ctx.Darwin() {
+		if !ctx.Darwin() && tc.GccSupported() {
 			gccPrefix = "-B" + filepath.Join(tc.GccRoot(), tc.GccTriple(), "bin")
 		}
 
 		flags.CFlags = append(flags.CFlags, target, gccPrefix)
 		flags.AsFlags = append(flags.AsFlags, target, gccPrefix)
 		flags.LdFlags = append(flags.LdFlags, target, gccPrefix)
+
+		flags.LdFlags = append(flags.LdFlags, clangLinkerFlags(tc)...)
 	}
 
 	hod := "Host"
@@ -344,6 +347,15 @@ func (compiler *baseCompiler) compilerFlags(ctx ModuleContext, flags Flags) Flag
 	return flags
 }
 
+// clangLinkerFlags returns the flags that select the linker used by clang for a toolchain
+func clangLinkerFlags(tc config.Toolchain) []string {
+	if !tc.GccSupported() {
+		// There is no GNU ld without GCC
+		return []string{"-fuse-ld=lld"}
+	}
+	return nil
+}
+
 func (compiler *baseCompiler) hasSrcExt(ext string) bool {
 	for _, src := range compiler.Properties.Srcs {
 		if filepath.Ext(src) == ext {
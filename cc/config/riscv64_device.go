@@ -0,0 +1,182 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"android/soong/android"
+)
+
+var (
+	riscv64Cflags = []string{
+		"-fno-exceptions", // from build/core/combo/select.mk
+		"-Wno-multichar",  // from build/core/combo/select.mk
+		"-fno-strict-aliasing",
+		"-fstack-protector-strong",
+		"-ffunction-sections",
+		"-fdata-sections",
+		"-funwind-tables",
+		"-Wa,--noexecstack",
+		"-Werror=format-security",
+		"-D_FORTIFY_SOURCE=2",
+		"-fno-short-enums",
+		"-no-canonical-prefixes",
+
+		// Help catch common 32/64-bit errors.
+		"-Werror=pointer-to-int-cast",
+		"-Werror=int-to-pointer-cast",
+		"-Werror=implicit-function-declaration",
+
+		// TARGET_RELEASE_CFLAGS
+		"-DNDEBUG",
+		"-O2 -g",
+	}
+
+	riscv64Ldflags = []string{
+		"-Wl,-z,noexecstack",
+		"-Wl,-z,relro",
+		"-Wl,-z,now",
+		"-Wl,--build-id=md5",
+		"-Wl,--warn-shared-textrel",
+		"-Wl,--fatal-warnings",
+		"-Wl,--hash-style=gnu",
+		"-Wl,--no-undefined-version",
+
+		// Disable transitive dependency library symbol resolving.
+		"-Wl,--allow-shlib-undefined",
+	}
+
+	riscv64Cppflags = []string{
+		"-fvisibility-inlines-hidden",
+	}
+
+	riscv64ArchVariantCflags = map[string][]string{
+		"rv64gc": []string{
+			"-march=rv64gc",
+			"-mabi=lp64d",
+		},
+		"rv64gcv": []string{
+			"-march=rv64gcv",
+			"-mabi=lp64d",
+		},
+	}
+)
+
+func init() {
+	android.RegisterArchVariants(android.Riscv64,
+		"rv64gc",
+		"rv64gcv")
+	android.RegisterArchFeatures(android.Riscv64, "vector")
+	android.RegisterArchVariantFeatures(android.Riscv64, "rv64gcv",
+		"vector")
+
+	// There is no GCC for riscv64, so there are no separate GCC and clang flags
+	pctx.StaticVariable("Riscv64Cflags", strings.Join(riscv64Cflags, " "))
+	pctx.StaticVariable("Riscv64Ldflags", strings.Join(riscv64Ldflags, " "))
+	pctx.StaticVariable("Riscv64Cppflags", strings.Join(riscv64Cppflags, " "))
+	pctx.StaticVariable("Riscv64IncludeFlags", bionicHeaders("riscv64", "riscv"))
+
+	// Architecture variant cflags
+	for variant, cflags := range riscv64ArchVariantCflags {
+		pctx.StaticVariable("Riscv64"+variant+"VariantCflags", strings.Join(cflags, " "))
+	}
+}
+
+// toolchainRiscv64 is a clang-only toolchain, GCC does not support riscv64 Android targets.
+type toolchainRiscv64 struct {
+	toolchain64Bit
+
+	toolchainCflags string
+}
+
+func (t *toolchainRiscv64) Name() string {
+	return "riscv64"
+}
+
+func (t *toolchainRiscv64) GccSupported() bool {
+	return false
+}
+
+func (t *toolchainRiscv64) GccRoot() string {
+	return ""
+}
+
+func (t *toolchainRiscv64) GccTriple() string {
+	return "riscv64-linux-android"
+}
+
+func (t *toolchainRiscv64) GccVersion() string {
+	return ""
+}
+
+func (t *toolchainRiscv64) ToolchainCflags() string {
+	return t.toolchainCflags
+}
+
+func (t *toolchainRiscv64) Cflags() string {
+	return "${config.Riscv64Cflags}"
+}
+
+func (t *toolchainRiscv64) Cppflags() string {
+	return "${config.Riscv64Cppflags}"
+}
+
+func (t *toolchainRiscv64) Ldflags() string {
+	return "${config.Riscv64Ldflags}"
+}
+
+func (t *toolchainRiscv64) IncludeFlags() string {
+	return "${config.Riscv64IncludeFlags}"
+}
+
+func (t *toolchainRiscv64) ClangTriple() string {
+	return t.GccTriple()
+}
+
+func (t *toolchainRiscv64) ClangCflags() string {
+	return t.Cflags()
+}
+
+func (t *toolchainRiscv64) ClangCppflags() string {
+	return t.Cppflags()
+}
+
+func (t *toolchainRiscv64) ClangLdflags() string {
+	return t.Ldflags()
+}
+
+func (t *toolchainRiscv64) ToolchainClangCflags() string {
+	return t.toolchainCflags
+}
+
+func (toolchainRiscv64) SanitizerRuntimeLibraryArch() string {
+	return "riscv64"
+}
+
+func riscv64ToolchainFactory(arch android.Arch) Toolchain {
+	if _, ok := riscv64ArchVariantCflags[arch.ArchVariant]; !ok {
+		panic(fmt.Sprintf("Unknown RISC-V architecture version: %q", arch.ArchVariant))
+	}
+
+	return &toolchainRiscv64{
+		toolchainCflags: "${config.Riscv64" + arch.ArchVariant + "VariantCflags}",
+	}
+}
+
+func init() {
+	registerToolchainFactory(android.Android, android.Riscv64, riscv64ToolchainFactory)
+}
@@ -16,6 +16,7 @@ package config
 
 import (
 	"fmt"
+	"strings"
 
 	"android/soong/android"
 )
@@ -37,12 +38,49 @@ func FindToolchain(os android.OsType, arch android.Arch) Toolchain {
 		panic(fmt.Errorf("Toolchain not found for %s arch %q", os.String(), arch.String()))
 		return nil
 	}
-	return factory(arch)
+
+	// Variants declared in the product's arch variants file use the toolchain of their base
+	// variant with their own cflags added.
+	var cflags []string
+	if arch.ConfigArchVariant != nil {
+		cflags = append(cflags, arch.ConfigArchVariant.Cflags...)
+	}
+	if arch.ConfigCpuVariant != nil {
+		cflags = append(cflags, arch.ConfigCpuVariant.Cflags...)
+	}
+
+	toolchain := factory(arch.BaseVariants())
+	if len(cflags) > 0 {
+		toolchain = &toolchainConfigVariant{
+			Toolchain:   toolchain,
+			cflags:      strings.Join(cflags, " "),
+			clangCflags: strings.Join(ClangFilterUnknownCflags(cflags), " "),
+		}
+	}
+	return toolchain
+}
+
+// toolchainConfigVariant adds the cflags of a variant declared in the arch variants file to the
+// toolchain of its base variant.
+type toolchainConfigVariant struct {
+	Toolchain
+	cflags, clangCflags string
+}
+
+func (t *toolchainConfigVariant) ToolchainCflags() string {
+	return t.Toolchain.ToolchainCflags() + " " + t.cflags
+}
+
+func (t *toolchainConfigVariant) ToolchainClangCflags() string {
+	return t.Toolchain.ToolchainClangCflags() + " " + t.clangCflags
 }
 
 type Toolchain interface {
 	Name() string
 
+	// GccSupported returns false for toolchains that only have clang.  Modules are always
+	// compiled with clang, GccRoot and GccVersion are empty, and the binutils come from LLVM.
+	GccSupported() bool
 	GccRoot() string
 	GccTriple() string
 	// GccVersion should return a real value, not a ninja reference
@@ -113,6 +151,10 @@ func (toolchainBase) ToolchainClangLdflags() string {
 	return ""
 }
 
+func (toolchainBase) GccSupported() bool {
+	return true
+}
+
 func (toolchainBase) ClangSupported() bool {
 	return true
 }
@@ -0,0 +1,109 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestRiscv64Toolchain(t *testing.T) {
+	testCases := []struct {
+		archVariant string
+		cflags      string
+	}{
+		{"rv64gc", "${config.Riscv64rv64gcVariantCflags}"},
+		{"rv64gcv", "${config.Riscv64rv64gcvVariantCflags}"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.archVariant, func(t *testing.T) {
+			arch := android.Arch{
+				ArchType:    android.Riscv64,
+				ArchVariant: testCase.archVariant,
+			}
+			tc := FindToolchain(android.Android, arch)
+
+			if tc.Name() != "riscv64" {
+				t.Errorf("expected toolchain %q, got %q", "riscv64", tc.Name())
+			}
+			if tc.GccSupported() {
+				t.Error("expected riscv64 to have no GCC toolchain")
+			}
+			if !tc.ClangSupported() {
+				t.Error("expected riscv64 to be supported by clang")
+			}
+			if !tc.Is64Bit() {
+				t.Error("expected riscv64 to be 64-bit")
+			}
+			if !tc.Bionic() {
+				t.Error("expected riscv64 to use bionic")
+			}
+			if tc.ClangTriple() != "riscv64-linux-android" {
+				t.Errorf("expected clang triple %q, got %q", "riscv64-linux-android", tc.ClangTriple())
+			}
+			if tc.ToolchainClangCflags() != testCase.cflags {
+				t.Errorf("expected variant cflags %q, got %q", testCase.cflags, tc.ToolchainClangCflags())
+			}
+			if tc.ClangLdflags() != "${config.Riscv64Ldflags}" {
+				t.Errorf("expected ldflags %q, got %q", "${config.Riscv64Ldflags}", tc.ClangLdflags())
+			}
+		})
+	}
+}
+
+func TestRiscv64ToolchainUnknownVariant(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected a panic for an unknown riscv64 arch variant")
+		}
+	}()
+
+	FindToolchain(android.Android, android.Arch{
+		ArchType:    android.Riscv64,
+		ArchVariant: "rv32gc",
+	})
+}
+
+func TestLinuxHostToolchains(t *testing.T) {
+	testCases := []struct {
+		os          android.OsType
+		clangTriple string
+		bionic      bool
+	}{
+		{android.LinuxBionic, "x86_64-linux-android", true},
+		{android.LinuxMusl, "x86_64-linux-musl", false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.os.String(), func(t *testing.T) {
+			tc := FindToolchain(testCase.os, android.Arch{ArchType: android.X86_64})
+
+			if tc.Name() != "x86_64" {
+				t.Errorf("expected toolchain %q, got %q", "x86_64", tc.Name())
+			}
+			if !tc.Is64Bit() {
+				t.Error("expected a 64-bit toolchain")
+			}
+			if tc.Bionic() != testCase.bionic {
+				t.Errorf("expected Bionic() %t, got %t", testCase.bionic, tc.Bionic())
+			}
+			if tc.ClangTriple() != testCase.clangTriple {
+				t.Errorf("expected clang triple %q, got %q", testCase.clangTriple, tc.ClangTriple())
+			}
+		})
+	}
+}
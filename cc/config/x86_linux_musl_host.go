@@ -0,0 +1,199 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"strings"
+
+	"android/soong/android"
+)
+
+// linux_musl host binaries are built against the musl libc from a prebuilt musl cross toolchain,
+// so that they can be linked statically and run on hosts without a compatible glibc.
+
+var (
+	linuxMuslCflags = []string{
+		"-fno-exceptions", // from build/core/combo/select.mk
+		"-Wno-multichar",  // from build/core/combo/select.mk
+
+		"-fdiagnostics-color",
+
+		"-Wa,--noexecstack",
+
+		"-fPIC",
+		"-no-canonical-prefixes",
+
+		"-U_FORTIFY_SOURCE",
+		"-D_FORTIFY_SOURCE=2",
+		"-fstack-protector",
+
+		// Workaround differences in inttypes.h between host and target.
+		//See bug 12708004.
+		"-D__STDC_FORMAT_MACROS",
+		"-D__STDC_CONSTANT_MACROS",
+
+		// HOST_RELEASE_CFLAGS
+		"-O2",                  // from build/core/combo/select.mk
+		"-g",                   // from build/core/combo/select.mk
+		"-fno-strict-aliasing", // from build/core/combo/select.mk
+	}
+
+	linuxMuslLdflags = []string{
+		"-Wl,-z,noexecstack",
+		"-Wl,-z,relro",
+		"-Wl,-z,now",
+		"-Wl,--no-undefined-version",
+	}
+
+	linuxMuslX8664Cflags = []string{
+		"-m64",
+	}
+
+	linuxMuslX8664Ldflags = []string{
+		"-m64",
+	}
+
+	linuxMuslClangCflags = append(ClangFilterUnknownCflags(linuxMuslCflags), []string{
+		"--gcc-toolchain=${LinuxMuslGccRoot}",
+		"--sysroot ${LinuxMuslGccRoot}/${LinuxMuslGccTriple}",
+		"-fstack-protector-strong",
+	}...)
+
+	linuxMuslClangLdflags = append(ClangFilterUnknownCflags(linuxMuslLdflags), []string{
+		"--gcc-toolchain=${LinuxMuslGccRoot}",
+		"--sysroot ${LinuxMuslGccRoot}/${LinuxMuslGccTriple}",
+	}...)
+
+	linuxMuslX8664ClangLdflags = append(ClangFilterUnknownCflags(linuxMuslX8664Ldflags), []string{
+		"-B${LinuxMuslGccRoot}/lib/gcc/${LinuxMuslGccTriple}/${LinuxMuslGccVersion}",
+		"-L${LinuxMuslGccRoot}/lib/gcc/${LinuxMuslGccTriple}/${LinuxMuslGccVersion}",
+		"-L${LinuxMuslGccRoot}/${LinuxMuslGccTriple}/lib",
+	}...)
+
+	linuxMuslClangCppflags = []string{
+		"-isystem ${LinuxMuslGccRoot}/${LinuxMuslGccTriple}/include/c++/${LinuxMuslGccVersion}",
+		"-isystem ${LinuxMuslGccRoot}/${LinuxMuslGccTriple}/include/c++/${LinuxMuslGccVersion}/${LinuxMuslGccTriple}",
+		"-isystem ${LinuxMuslGccRoot}/${LinuxMuslGccTriple}/include/c++/${LinuxMuslGccVersion}/backward",
+	}
+
+	// musl provides all of these in libc.a, but the empty archives are kept for compatibility
+	linuxMuslAvailableLibraries = addPrefix([]string{
+		"c",
+		"dl",
+		"m",
+		"pthread",
+		"resolv",
+		"rt",
+		"util",
+	}, "-l")
+)
+
+const (
+	linuxMuslGccVersion = "4.9"
+)
+
+func init() {
+	pctx.StaticVariable("LinuxMuslGccVersion", linuxMuslGccVersion)
+
+	pctx.SourcePathVariable("LinuxMuslGccRoot",
+		"prebuilts/gcc/${HostPrebuiltTag}/host/x86_64-linux-musl-${LinuxMuslGccVersion}")
+
+	pctx.StaticVariable("LinuxMuslGccTriple", "x86_64-linux-musl")
+
+	pctx.StaticVariable("LinuxMuslCflags", strings.Join(linuxMuslCflags, " "))
+	pctx.StaticVariable("LinuxMuslLdflags", strings.Join(linuxMuslLdflags, " "))
+
+	pctx.StaticVariable("LinuxMuslClangCflags", strings.Join(linuxMuslClangCflags, " "))
+	pctx.StaticVariable("LinuxMuslClangLdflags", strings.Join(linuxMuslClangLdflags, " "))
+	pctx.StaticVariable("LinuxMuslClangCppflags", strings.Join(linuxMuslClangCppflags, " "))
+
+	// Extended cflags
+	pctx.StaticVariable("LinuxMuslX8664Cflags", strings.Join(linuxMuslX8664Cflags, " "))
+	pctx.StaticVariable("LinuxMuslX8664Ldflags", strings.Join(linuxMuslX8664Ldflags, " "))
+
+	pctx.StaticVariable("LinuxMuslX8664ClangCflags",
+		strings.Join(ClangFilterUnknownCflags(linuxMuslX8664Cflags), " "))
+	pctx.StaticVariable("LinuxMuslX8664ClangLdflags", strings.Join(linuxMuslX8664ClangLdflags, " "))
+}
+
+type toolchainLinuxMuslX8664 struct {
+	toolchain64Bit
+}
+
+func (t *toolchainLinuxMuslX8664) Name() string {
+	return "x86_64"
+}
+
+func (t *toolchainLinuxMuslX8664) GccRoot() string {
+	return "${config.LinuxMuslGccRoot}"
+}
+
+func (t *toolchainLinuxMuslX8664) GccTriple() string {
+	return "${config.LinuxMuslGccTriple}"
+}
+
+func (t *toolchainLinuxMuslX8664) GccVersion() string {
+	return linuxMuslGccVersion
+}
+
+func (t *toolchainLinuxMuslX8664) Cflags() string {
+	return "${config.LinuxMuslCflags} ${config.LinuxMuslX8664Cflags}"
+}
+
+func (t *toolchainLinuxMuslX8664) Cppflags() string {
+	return ""
+}
+
+func (t *toolchainLinuxMuslX8664) Ldflags() string {
+	return "${config.LinuxMuslLdflags} ${config.LinuxMuslX8664Ldflags}"
+}
+
+func (t *toolchainLinuxMuslX8664) IncludeFlags() string {
+	return ""
+}
+
+func (t *toolchainLinuxMuslX8664) ClangTriple() string {
+	return "x86_64-linux-musl"
+}
+
+func (t *toolchainLinuxMuslX8664) ClangCflags() string {
+	return "${config.LinuxMuslClangCflags} ${config.LinuxMuslX8664ClangCflags}"
+}
+
+func (t *toolchainLinuxMuslX8664) ClangCppflags() string {
+	return "${config.LinuxMuslClangCppflags}"
+}
+
+func (t *toolchainLinuxMuslX8664) ClangLdflags() string {
+	return "${config.LinuxMuslClangLdflags} ${config.LinuxMuslX8664ClangLdflags}"
+}
+
+func (t *toolchainLinuxMuslX8664) AvailableLibraries() []string {
+	return linuxMuslAvailableLibraries
+}
+
+func (t *toolchainLinuxMuslX8664) Bionic() bool {
+	return false
+}
+
+var toolchainLinuxMuslX8664Singleton Toolchain = &toolchainLinuxMuslX8664{}
+
+func linuxMuslX8664ToolchainFactory(arch android.Arch) Toolchain {
+	return toolchainLinuxMuslX8664Singleton
+}
+
+func init() {
+	registerToolchainFactory(android.LinuxMusl, android.X86_64, linuxMuslX8664ToolchainFactory)
+}
@@ -67,9 +67,7 @@ func (installer *baseInstaller) install(ctx ModuleContext, file android.Path) {
 	if ctx.toolchain().Is64Bit() && installer.dir64 != "" {
 		subDir = installer.dir64
 	}
-	if !ctx.Host() && !ctx.Arch().Native {
-		subDir = filepath.Join(subDir, ctx.Arch().ArchType.String())
-	}
+	subDir = targetSubDir(subDir, ctx.Target())
 	dir := android.PathForModuleInstall(ctx, subDir, installer.Properties.Relative_install_path, installer.relative)
 	installer.path = ctx.InstallFile(dir, file)
 	for _, symlink := range installer.Properties.Symlinks {
@@ -77,6 +75,18 @@ func (installer *baseInstaller) install(ctx ModuleContext, file android.Path) {
 	}
 }
 
+// targetSubDir returns the directory that files in dir are installed into for a target.  Native
+// bridge targets use their relative path, and other non-native device arches use their arch name,
+// for example lib/arm.
+func targetSubDir(dir string, target android.Target) string {
+	if target.NativeBridge {
+		return filepath.Join(dir, target.NativeBridgeRelativePath)
+	} else if target.Os.Class == android.Device && !target.Arch.Native {
+		return filepath.Join(dir, target.Arch.ArchType.String())
+	}
+	return dir
+}
+
 func (installer *baseInstaller) inData() bool {
 	return installer.location == InstallInData
 }
@@ -25,7 +25,7 @@ import (
 
 type LibraryProperties struct {
 	Static struct {
-		Srcs   []string `android:"arch_variant"`
+		Srcs   []string `android:"arch_variant,path"`
 		Cflags []string `android:"arch_variant"`
 
 		Enabled           *bool    `android:"arch_variant"`
@@ -34,7 +34,7 @@ type LibraryProperties struct {
 		Shared_libs       []string `android:"arch_variant"`
 	} `android:"arch_variant"`
 	Shared struct {
-		Srcs   []string `android:"arch_variant"`
+		Srcs   []string `android:"arch_variant,path"`
 		Cflags []string `android:"arch_variant"`
 
 		Enabled           *bool    `android:"arch_variant"`
@@ -267,11 +267,11 @@ func (library *libraryDecorator) compile(ctx ModuleContext, flags Flags, deps Pa
 	buildFlags := flagsToBuilderFlags(flags)
 
 	if library.static() {
-		srcs := android.PathsForModuleSrc(ctx, library.Properties.Static.Srcs)
+		srcs := ctx.ExpandSources(library.Properties.Static.Srcs, nil)
 		objs = objs.Append(compileObjs(ctx, buildFlags, android.DeviceStaticLibrary,
 			srcs, library.baseCompiler.deps))
 	} else {
-		srcs := android.PathsForModuleSrc(ctx, library.Properties.Shared.Srcs)
+		srcs := ctx.ExpandSources(library.Properties.Shared.Srcs, nil)
 		objs = objs.Append(compileObjs(ctx, buildFlags, android.DeviceSharedLibrary,
 			srcs, library.baseCompiler.deps))
 	}
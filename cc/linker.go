@@ -58,6 +58,10 @@ type BaseLinkerProperties struct {
 	// don't link in libgcc.a
 	No_libgcc *bool
 
+	// don't link in libcompiler_rt-extras.a, or libcompiler_rt.a for toolchains without GCC.
+	// Set by the compiler-rt libraries themselves.
+	No_libcompiler_rt *bool
+
 	// -l arguments to pass to linker for host-provided shared libraries
 	Host_ldlibs []string `android:"arch_variant"`
 
@@ -119,15 +123,20 @@ func (linker *baseLinker) linkerDeps(ctx BaseModuleContext, deps Deps) Deps {
 	deps.ReexportSharedLibHeaders = append(deps.ReexportSharedLibHeaders, linker.Properties.Export_shared_lib_headers...)
 	deps.ReexportGeneratedHeaders = append(deps.ReexportGeneratedHeaders, linker.Properties.Export_generated_headers...)
 
-	if ctx.ModuleName() != "libcompiler_rt-extras" {
+	if !Bool(linker.Properties.No_libcompiler_rt) {
 		deps.LateStaticLibs = append(deps.LateStaticLibs, "libcompiler_rt-extras")
 	}
 
 	if ctx.toolchain().Bionic() {
 		// libgcc and libatomic have to be last on the command line
-		deps.LateStaticLibs = append(deps.LateStaticLibs, "libatomic")
-		if !Bool(linker.Properties.No_libgcc) {
-			deps.LateStaticLibs = append(deps.LateStaticLibs, "libgcc")
+		if ctx.toolchain().GccSupported() {
+			deps.LateStaticLibs = append(deps.LateStaticLibs, "libatomic")
+			if !Bool(linker.Properties.No_libgcc) {
+				deps.LateStaticLibs = append(deps.LateStaticLibs, "libgcc")
+			}
+		} else if !Bool(linker.Properties.No_libgcc) && !Bool(linker.Properties.No_libcompiler_rt) {
+			// Without GCC the compiler runtime comes from compiler-rt
+			deps.LateStaticLibs = append(deps.LateStaticLibs, "libcompiler_rt")
 		}
 
 		if !ctx.static() {
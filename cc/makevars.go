@@ -75,7 +75,13 @@ func makeVarsProvider(ctx android.MakeVarsContext) {
 	sort.Strings(ndkMigratedLibs)
 	ctx.Strict("NDK_MIGRATED_LIBS", strings.Join(ndkMigratedLibs, " "))
 
-	hostTargets := ctx.Config().Targets[android.Host]
+	// Make only knows about the build OS, skip other host OSes such as linux_bionic
+	var hostTargets []android.Target
+	for _, target := range ctx.Config().Targets[android.Host] {
+		if target.Os == android.BuildOs {
+			hostTargets = append(hostTargets, target)
+		}
+	}
 	makeVarsToolchain(ctx, "", hostTargets[0])
 	if len(hostTargets) > 1 {
 		makeVarsToolchain(ctx, "2ND_", hostTargets[1])
@@ -89,7 +95,13 @@ func makeVarsProvider(ctx android.MakeVarsContext) {
 		}
 	}
 
-	deviceTargets := ctx.Config().Targets[android.Device]
+	// Make doesn't know about native bridge targets
+	var deviceTargets []android.Target
+	for _, target := range ctx.Config().Targets[android.Device] {
+		if !target.NativeBridge {
+			deviceTargets = append(deviceTargets, target)
+		}
+	}
 	makeVarsToolchain(ctx, "", deviceTargets[0])
 	if len(deviceTargets) > 1 {
 		makeVarsToolchain(ctx, "2ND_", deviceTargets[1])
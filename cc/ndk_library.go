@@ -116,12 +116,13 @@ func normalizeNdkApiLevel(apiLevel string, arch android.Arch) (string, error) {
 
 	minVersion := 9 // Minimum version supported by the NDK.
 	firstArchVersions := map[string]int{
-		"arm":    9,
-		"arm64":  21,
-		"mips":   9,
-		"mips64": 21,
-		"x86":    9,
-		"x86_64": 21,
+		"arm":     9,
+		"arm64":   21,
+		"mips":    9,
+		"mips64":  21,
+		"riscv64": 26,
+		"x86":     9,
+		"x86_64":  21,
 	}
 
 	// If the NDK drops support for a platform version, we don't want to have to
@@ -264,10 +265,10 @@ func (stub *stubDecorator) install(ctx ModuleContext, path android.Path) {
 	arch := ctx.Target().Arch.ArchType.Name
 	apiLevel := stub.properties.ApiLevel
 
-	// arm64 isn't actually a multilib toolchain, so unlike the other LP64
-	// architectures it's just installed to lib.
+	// arm64 and riscv64 aren't actually multilib toolchains, so unlike the
+	// other LP64 architectures they're just installed to lib.
 	libDir := "lib"
-	if ctx.toolchain().Is64Bit() && arch != "arm64" {
+	if ctx.toolchain().Is64Bit() && arch != "arm64" && arch != "riscv64" {
 		libDir = "lib64"
 	}
 
@@ -39,9 +39,9 @@ func init() {
 
 func getNdkLibDir(ctx android.ModuleContext, toolchain config.Toolchain, version string) android.SourcePath {
 	suffix := ""
-	// Most 64-bit NDK prebuilts store libraries in "lib64", except for arm64 which is not a
-	// multilib toolchain and stores the libraries in "lib".
-	if toolchain.Is64Bit() && ctx.Arch().ArchType != android.Arm64 {
+	// Most 64-bit NDK prebuilts store libraries in "lib64", except for arm64 and riscv64 which are
+	// not multilib toolchains and store the libraries in "lib".
+	if toolchain.Is64Bit() && ctx.Arch().ArchType != android.Arm64 && ctx.Arch().ArchType != android.Riscv64 {
 		suffix = "64"
 	}
 	return android.PathForSource(ctx, fmt.Sprintf("prebuilts/ndk/current/platforms/android-%s/arch-%s/usr/lib%s",
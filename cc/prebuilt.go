@@ -22,6 +22,7 @@ import (
 
 func init() {
 	android.RegisterModuleType("cc_prebuilt_shared_library", prebuiltSharedLibraryFactory)
+	android.RegisterModuleType("cc_prebuilt_defaults", prebuiltDefaultsFactory)
 }
 
 type prebuiltLinkerInterface interface {
@@ -71,3 +72,11 @@ func prebuiltSharedLibraryFactory() (blueprint.Module, []interface{}) {
 
 	return module.Init()
 }
+
+// prebuiltDefaultsFactory returns a defaults module for the srcs and prefer properties of cc
+// prebuilts.  They are not in cc_defaults, where srcs would also set the compiler srcs.
+func prebuiltDefaultsFactory() (blueprint.Module, []interface{}) {
+	module := &Defaults{}
+
+	return android.InitDefaultsModule(module, module, &android.PrebuiltProperties{})
+}
@@ -97,6 +97,12 @@ type sanitize struct {
 	Properties SanitizeProperties
 }
 
+func init() {
+	// The names handled by sanitize.begin in the SanitizeHost and SanitizeDevice product variables
+	android.RegisterGlobalSanitizers("undefined", "default-ub", "address", "thread", "coverage",
+		"safe-stack", "cfi")
+}
+
 func (sanitize *sanitize) props() []interface{} {
 	return []interface{}{&sanitize.Properties}
 }
@@ -132,9 +132,11 @@ func (test *testDecorator) linkerFlags(ctx ModuleContext, flags Flags) Flags {
 		switch ctx.Os() {
 		case android.Windows:
 			flags.CFlags = append(flags.CFlags, "-DGTEST_OS_WINDOWS")
-		case android.Linux:
+		case android.Linux, android.LinuxMusl:
 			flags.CFlags = append(flags.CFlags, "-DGTEST_OS_LINUX")
 			flags.LdFlags = append(flags.LdFlags, "-lpthread")
+		case android.LinuxBionic:
+			flags.CFlags = append(flags.CFlags, "-DGTEST_OS_LINUX")
 		case android.Darwin:
 			flags.CFlags = append(flags.CFlags, "-DGTEST_OS_MAC")
 			flags.LdFlags = append(flags.LdFlags, "-lpthread")
@@ -63,6 +63,14 @@ func (library *toolchainLibraryDecorator) link(ctx ModuleContext,
 	libName := ctx.ModuleName() + staticLibraryExtension
 	outputFile := android.PathForModuleOut(ctx, libName)
 
+	if !ctx.toolchain().GccSupported() {
+		// There is no GCC to copy the library from, modules built with this toolchain link
+		// against libcompiler_rt instead
+		ctx.ModuleErrorf("toolchain_library is not supported for %s, which has no GCC toolchain",
+			ctx.Arch().ArchType)
+		return nil
+	}
+
 	if flags.Clang {
 		ctx.ModuleErrorf("toolchain_library must use GCC, not Clang")
 	}
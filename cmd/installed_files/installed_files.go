@@ -0,0 +1,87 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// installed_files reads the installed-files-list.json file written by soong_build and writes it
+// out again with the size of every installed file, which is only known once the files have been
+// installed.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+var out = flag.String("o", "", "output file")
+
+// installedFile must match the struct of the same name in android/installed_files.go, with the
+// addition of Size.
+type installedFile struct {
+	Path    string
+	Module  string
+	Variant string `json:",omitempty"`
+	Source  string `json:",omitempty"`
+	Symlink string `json:",omitempty"`
+	Size    int64
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: installed_files -o <output file> <installed-files-list.json>\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if *out == "" || flag.NArg() != 1 {
+		usage()
+	}
+
+	if err := run(flag.Arg(0), *out); err != nil {
+		fmt.Fprintln(os.Stderr, "installed_files:", err)
+		os.Exit(1)
+	}
+}
+
+func run(in, out string) error {
+	data, err := ioutil.ReadFile(in)
+	if err != nil {
+		return err
+	}
+
+	var files []installedFile
+	if err := json.Unmarshal(data, &files); err != nil {
+		return fmt.Errorf("failed to parse %s: %s", in, err.Error())
+	}
+
+	for i := range files {
+		// Use Lstat so that symlinks report the size of the link and not of its target
+		info, err := os.Lstat(files[i].Path)
+		if err != nil {
+			return err
+		}
+		files[i].Size = info.Size()
+	}
+
+	data, err = json.MarshalIndent(files, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(out, append(data, '\n'), 0666)
+}
@@ -0,0 +1,216 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// sbox runs a command in a temporary directory that contains only symlinks to the declared
+// inputs of the command, and then moves the declared outputs to their real locations.  It fails
+// if the command does not write every declared output, or if it writes any other file.  All
+// input and output paths are relative to the top of the source tree, and keep the same relative
+// paths inside the sandbox so that the command does not need to be rewritten.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type fileList []string
+
+func (l *fileList) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *fileList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+var (
+	sandboxPath = flag.String("sandbox-path", "", "directory to create the temporary sandbox in")
+	command     = flag.String("c", "", "command to run in the sandbox")
+	inputs      fileList
+	outputs     fileList
+)
+
+func init() {
+	flag.Var(&inputs, "i", "input file to make available in the sandbox (may be repeated)")
+	flag.Var(&outputs, "o", "output file the command must write (may be repeated)")
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: sbox -sandbox-path <dir> -c <command> [-i <input>]... -o <output>...\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if *sandboxPath == "" || *command == "" || len(outputs) == 0 || flag.NArg() != 0 {
+		usage()
+	}
+
+	if err := run(*sandboxPath, *command, inputs, outputs); err != nil {
+		fmt.Fprintln(os.Stderr, "sbox:", err)
+		os.Exit(1)
+	}
+}
+
+func run(sandboxPath, command string, inputs, outputs []string) error {
+	for _, out := range outputs {
+		if filepath.IsAbs(out) || strings.HasPrefix(filepath.Clean(out), "../") {
+			return fmt.Errorf("output %q is not relative to the top of the source tree", out)
+		}
+	}
+
+	if err := os.MkdirAll(sandboxPath, 0777); err != nil {
+		return err
+	}
+	dir, err := ioutil.TempDir(sandboxPath, "sbox")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	if err := setupSandbox(dir, inputs, outputs); err != nil {
+		return err
+	}
+
+	cmd := exec.Command("/bin/bash", "-c", command)
+	cmd.Dir = dir
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("command failed: %s", err.Error())
+	}
+
+	if err := checkOutputs(dir, inputs, outputs); err != nil {
+		return err
+	}
+
+	return moveOutputs(dir, outputs)
+}
+
+// setupSandbox creates a symlink in dir to the absolute path of every relative input, and the
+// parent directories of every output.  Absolute inputs are outside the source tree and are
+// reachable from the sandbox without a symlink.
+func setupSandbox(dir string, inputs, outputs []string) error {
+	for _, in := range inputs {
+		if filepath.IsAbs(in) {
+			continue
+		}
+		target, err := filepath.Abs(in)
+		if err != nil {
+			return err
+		}
+		link := filepath.Join(dir, in)
+		if _, err := os.Lstat(link); err == nil {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(link), 0777); err != nil {
+			return err
+		}
+		if err := os.Symlink(target, link); err != nil {
+			return err
+		}
+	}
+
+	for _, out := range outputs {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(out)), 0777); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkOutputs returns an error if any output is missing from dir, or if dir contains any file
+// that is neither an output nor a symlink to an input created by setupSandbox.
+func checkOutputs(dir string, inputs, outputs []string) error {
+	declared := make(map[string]bool)
+	for _, out := range outputs {
+		declared[filepath.Clean(out)] = true
+	}
+	symlinks := make(map[string]bool)
+	for _, in := range inputs {
+		symlinks[filepath.Clean(in)] = true
+	}
+
+	var missing, undeclared []string
+	for _, out := range outputs {
+		info, err := os.Lstat(filepath.Join(dir, out))
+		if err != nil || info.IsDir() {
+			missing = append(missing, out)
+		}
+	}
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if declared[rel] {
+			return nil
+		}
+		if info.Mode()&os.ModeSymlink != 0 && symlinks[rel] {
+			return nil
+		}
+		undeclared = append(undeclared, rel)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+	if len(missing) > 0 {
+		errs = append(errs, "missing outputs:\n    "+strings.Join(missing, "\n    "))
+	}
+	if len(undeclared) > 0 {
+		sort.Strings(undeclared)
+		errs = append(errs, "undeclared outputs:\n    "+strings.Join(undeclared, "\n    "))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
+
+	return nil
+}
+
+// moveOutputs moves every output from dir to the same relative path outside the sandbox.  The
+// sandbox is created inside the output directory, so a rename is enough to copy the files back.
+func moveOutputs(dir string, outputs []string) error {
+	for _, out := range outputs {
+		if err := os.MkdirAll(filepath.Dir(out), 0777); err != nil {
+			return err
+		}
+		if err := os.Rename(filepath.Join(dir, out), out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var checkOutputsTestCases = []struct {
+	name    string
+	inputs  []string
+	outputs []string
+	write   []string
+	err     bool
+}{
+	{
+		name:    "all outputs",
+		inputs:  []string{"a/in.txt", "tools/gen.sh"},
+		outputs: []string{"out/gen/a.h", "out/gen/b.h"},
+		write:   []string{"out/gen/a.h", "out/gen/b.h"},
+	},
+	{
+		name:    "missing output",
+		inputs:  []string{"a/in.txt"},
+		outputs: []string{"out/gen/a.h", "out/gen/b.h"},
+		write:   []string{"out/gen/a.h"},
+		err:     true,
+	},
+	{
+		name:    "undeclared output",
+		inputs:  []string{"a/in.txt"},
+		outputs: []string{"out/gen/a.h"},
+		write:   []string{"out/gen/a.h", "out/gen/tmp.o"},
+		err:     true,
+	},
+	{
+		name:    "overwritten input",
+		inputs:  []string{"a/in.txt"},
+		outputs: []string{"out/gen/a.h"},
+		write:   []string{"out/gen/a.h", "a/in.txt"},
+		err:     true,
+	},
+}
+
+func TestCheckOutputs(t *testing.T) {
+	for _, test := range checkOutputsTestCases {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "sbox_test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			if err := setupSandbox(dir, test.inputs, test.outputs); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, file := range test.write {
+				path := filepath.Join(dir, file)
+				os.Remove(path)
+				if err := ioutil.WriteFile(path, nil, 0666); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err = checkOutputs(dir, test.inputs, test.outputs)
+			if err != nil && !test.err {
+				t.Errorf("unexpected error %s", err.Error())
+			} else if err == nil && test.err {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
@@ -25,24 +25,61 @@ import (
 	"android/soong/android"
 )
 
+var (
+	moduleGraphFile   string
+	diagnosticsFile   string
+	diagnosticsFormat string
+)
+
+func init() {
+	flag.StringVar(&moduleGraphFile, "module-graph", "",
+		"write a JSON description of the module graph to this file")
+	flag.StringVar(&diagnosticsFile, "diagnostics", "",
+		"also write errors reported by modules to this file")
+	flag.StringVar(&diagnosticsFormat, "diagnostics-format", "json",
+		"format of the --diagnostics file, json or sarif")
+}
+
 func main() {
 	flag.Parse()
 
+	if diagnosticsFile != "" && !android.InDiagnosticsChild() {
+		exitCode, err := android.RunWithDiagnostics(diagnosticsFile, diagnosticsFormat)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+		os.Exit(exitCode)
+	}
+
 	// The top-level Blueprints file is passed as the first argument.
 	srcDir := filepath.Dir(flag.Arg(0))
 
-	ctx := android.NewContext()
-
 	configuration, err := android.NewConfig(srcDir, bootstrap.BuildDir)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s", err)
 		os.Exit(1)
 	}
 
+	ctx := android.NewContextForConfig(configuration)
+
+	configuration.ModuleGraphFile = moduleGraphFile
+
+	if err := configuration.OpenDiagnosticsStream(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
 	// Temporary hack
 	//ctx.SetIgnoreUnknownModuleTypes(true)
 
 	ctx.SetAllowMissingDependencies(configuration.AllowMissingDependencies())
 
-	bootstrap.Main(ctx, configuration, configuration.ConfigFileName, configuration.ProductVariablesFileName)
+	extraNinjaFileDeps := []string{configuration.ConfigFileName, configuration.ProductVariablesFileName}
+	extraNinjaFileDeps = append(extraNinjaFileDeps, configuration.InheritedProductVariablesFileNames...)
+	if configuration.ArchVariantsFileName != "" {
+		extraNinjaFileDeps = append(extraNinjaFileDeps, configuration.ArchVariantsFileName)
+	}
+
+	bootstrap.Main(ctx, configuration, extraNinjaFileDeps...)
 }
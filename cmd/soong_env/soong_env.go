@@ -26,8 +26,10 @@ import (
 	"android/soong/env"
 )
 
+var explain = flag.Bool("explain", false, "print the modules that read each changed environment variable")
+
 func usage() {
-	fmt.Fprintf(os.Stderr, "usage: soong_env env_file\n")
+	fmt.Fprintf(os.Stderr, "usage: soong_env [--explain] env_file\n")
 	fmt.Fprintf(os.Stderr, "exits with success if the environment varibles in env_file match\n")
 	fmt.Fprintf(os.Stderr, "the current environment\n")
 	flag.PrintDefaults()
@@ -41,6 +43,35 @@ func main() {
 		usage()
 	}
 
+	if *explain {
+		changed, err := env.ChangedEnv(flag.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+			os.Exit(1)
+		}
+
+		readers, err := env.ReadEnvReadersFile(env.ReadersFile(flag.Arg(0)))
+		if err != nil && !os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+			os.Exit(1)
+		}
+
+		for _, c := range changed {
+			fmt.Printf("%s\n", c)
+			if len(readers[c.Key]) == 0 {
+				fmt.Printf("   no recorded readers\n")
+			}
+			for _, reader := range readers[c.Key] {
+				fmt.Printf("   read by %s\n", reader)
+			}
+		}
+
+		if len(changed) > 0 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	stale, err := env.StaleEnvFile(flag.Arg(0))
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
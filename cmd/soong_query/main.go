@@ -0,0 +1,120 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// soong_query loads the Android.bp files with the same module types, mutators and configuration
+// as soong_build, runs the mutators, and answers questions about the resulting module graph:
+//
+//	deps(a)        every module variant a depends on, directly or indirectly
+//	rdeps(a)       every module variant that depends on a, directly or indirectly
+//	somepath(a, b) a shortest dependency path from a to b
+//	allpaths(a, b) every dependency edge on any path from a to b
+//
+// The variants of the first module in the query can be restricted with -arch, -link and -asan.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/google/blueprint"
+	"github.com/google/blueprint/bootstrap"
+
+	"android/soong/android"
+)
+
+var filter variantFilter
+
+func init() {
+	flag.StringVar(&filter.arch, "arch", "", "only query variants for this architecture, e.g. arm64")
+	flag.StringVar(&filter.link, "link", "", "only query variants with this linkage, static or shared")
+	flag.BoolVar(&filter.asan, "asan", false, "only query address sanitizer variants")
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: soong_query -b <build dir> [-arch <arch>] [-link <static|shared>] [-asan] <Android.bp> <query>\n")
+	fmt.Fprintf(os.Stderr, "queries: deps(a), rdeps(a), somepath(a, b), allpaths(a, b)\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func fail(errs ...error) {
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, "error:", err)
+	}
+	os.Exit(1)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		usage()
+	}
+
+	rootFile, query := flag.Arg(0), flag.Arg(1)
+	srcDir := filepath.Dir(rootFile)
+
+	configuration, err := android.NewConfig(srcDir, bootstrap.BuildDir)
+	if err != nil {
+		fail(err)
+	}
+
+	ctx := android.NewContextForConfig(configuration)
+
+	// The bootstrap module types are only registered by soong_build
+	ctx.SetIgnoreUnknownModuleTypes(true)
+	ctx.SetAllowMissingDependencies(configuration.AllowMissingDependencies())
+
+	if _, errs := ctx.ParseBlueprintsFiles(rootFile); len(errs) > 0 {
+		fail(errs...)
+	}
+
+	if _, errs := ctx.ResolveDependencies(configuration); len(errs) > 0 {
+		fail(errs...)
+	}
+
+	output, err := buildGraph(ctx).runQuery(query, filter)
+	if err != nil {
+		fail(err)
+	}
+
+	for _, line := range output {
+		fmt.Println(line)
+	}
+}
+
+// buildGraph copies the mutated module graph out of the blueprint context.
+func buildGraph(ctx *blueprint.Context) *graph {
+	g := newGraph()
+	nodes := make(map[blueprint.Module]*node)
+
+	ctx.VisitAllModules(func(m blueprint.Module) {
+		arch := ""
+		if a, ok := m.(android.Module); ok {
+			arch = a.Target().Arch.ArchType.String()
+		}
+		nodes[m] = g.addNode(ctx.ModuleName(m), ctx.ModuleSubDir(m), arch)
+	})
+
+	ctx.VisitAllModules(func(m blueprint.Module) {
+		ctx.VisitDirectDeps(m, func(dep blueprint.Module) {
+			g.addEdge(nodes[m], nodes[dep])
+		})
+	})
+
+	return g
+}
@@ -0,0 +1,289 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// node is a single variant of a module in the mutated module graph.
+type node struct {
+	name    string
+	variant string
+	arch    string
+
+	deps  []*node
+	rdeps []*node
+}
+
+func (n *node) String() string {
+	if n.variant == "" {
+		return n.name
+	}
+	return n.name + " (" + n.variant + ")"
+}
+
+type graph struct {
+	nodes  []*node
+	byName map[string][]*node
+}
+
+func newGraph() *graph {
+	return &graph{
+		byName: make(map[string][]*node),
+	}
+}
+
+func (g *graph) addNode(name, variant, arch string) *node {
+	n := &node{name: name, variant: variant, arch: arch}
+	g.nodes = append(g.nodes, n)
+	g.byName[name] = append(g.byName[name], n)
+	return n
+}
+
+func (g *graph) addEdge(from, to *node) {
+	from.deps = append(from.deps, to)
+	to.rdeps = append(to.rdeps, from)
+}
+
+// variantFilter selects the variants of the modules named in a query.  Empty fields match every
+// variant.
+type variantFilter struct {
+	arch string
+	link string
+	asan bool
+}
+
+func (f variantFilter) matches(n *node) bool {
+	tokens := strings.Split(n.variant, "_")
+	hasToken := func(token string) bool {
+		for _, t := range tokens {
+			if t == token {
+				return true
+			}
+		}
+		return false
+	}
+
+	if f.arch != "" && n.arch != f.arch {
+		return false
+	}
+	if f.link != "" && !hasToken(f.link) {
+		return false
+	}
+	if f.asan && !hasToken("asan") {
+		return false
+	}
+	return true
+}
+
+// lookup returns the variants of the named module that match the filter.
+func (g *graph) lookup(name string, filter variantFilter) ([]*node, error) {
+	variants, ok := g.byName[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown module %q", name)
+	}
+
+	var ret []*node
+	for _, n := range variants {
+		if filter.matches(n) {
+			ret = append(ret, n)
+		}
+	}
+	if len(ret) == 0 {
+		return nil, fmt.Errorf("no variants of module %q match the variant filter", name)
+	}
+	return ret, nil
+}
+
+// reachable returns every node reachable from roots by following next, not including the roots
+// themselves unless they are reachable from another root.
+func reachable(roots []*node, next func(*node) []*node) map[*node]bool {
+	visited := make(map[*node]bool)
+	queue := append([]*node(nil), roots...)
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, m := range next(n) {
+			if !visited[m] {
+				visited[m] = true
+				queue = append(queue, m)
+			}
+		}
+	}
+	return visited
+}
+
+func depsOf(n *node) []*node  { return n.deps }
+func rdepsOf(n *node) []*node { return n.rdeps }
+
+func sortedNodes(set map[*node]bool) []string {
+	var ret []string
+	for n := range set {
+		ret = append(ret, n.String())
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// somepath returns a shortest path from one of the from nodes to one of the to nodes, or nil if
+// there is none.
+func somepath(from, to []*node) []*node {
+	isTarget := make(map[*node]bool)
+	for _, n := range to {
+		isTarget[n] = true
+	}
+
+	parent := make(map[*node]*node)
+	visited := make(map[*node]bool)
+	var queue []*node
+	for _, n := range from {
+		visited[n] = true
+		queue = append(queue, n)
+	}
+
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		if isTarget[n] {
+			var path []*node
+			for ; n != nil; n = parent[n] {
+				path = append([]*node{n}, path...)
+			}
+			return path
+		}
+		for _, m := range n.deps {
+			if !visited[m] {
+				visited[m] = true
+				parent[m] = n
+				queue = append(queue, m)
+			}
+		}
+	}
+
+	return nil
+}
+
+// allpaths returns every edge that lies on a path from one of the from nodes to one of the to
+// nodes, formatted as "a -> b".
+func allpaths(from, to []*node) []string {
+	forward := reachable(from, depsOf)
+	for _, n := range from {
+		forward[n] = true
+	}
+	backward := reachable(to, rdepsOf)
+	for _, n := range to {
+		backward[n] = true
+	}
+
+	var ret []string
+	for n := range forward {
+		if !backward[n] {
+			continue
+		}
+		for _, m := range n.deps {
+			if forward[m] && backward[m] {
+				ret = append(ret, n.String()+" -> "+m.String())
+			}
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+var queryRegexp = regexp.MustCompile(`^\s*(\w+)\s*\((.*)\)\s*$`)
+
+var queryArgs = map[string]int{
+	"deps":     1,
+	"rdeps":    1,
+	"somepath": 2,
+	"allpaths": 2,
+}
+
+// parseQuery splits a query of the form func(arg1, arg2) into the function name and arguments.
+func parseQuery(query string) (string, []string, error) {
+	matches := queryRegexp.FindStringSubmatch(query)
+	if matches == nil {
+		return "", nil, fmt.Errorf("invalid query %q, expected func(module[, module])", query)
+	}
+
+	function := matches[1]
+	var args []string
+	for _, arg := range strings.Split(matches[2], ",") {
+		arg = strings.TrimSpace(arg)
+		if arg == "" {
+			return "", nil, fmt.Errorf("invalid query %q, empty module name", query)
+		}
+		args = append(args, arg)
+	}
+
+	if n, ok := queryArgs[function]; !ok {
+		return "", nil, fmt.Errorf("unknown query function %q", function)
+	} else if len(args) != n {
+		return "", nil, fmt.Errorf("%s expects %d arguments, got %d", function, n, len(args))
+	}
+
+	return function, args, nil
+}
+
+// runQuery evaluates a query against the graph and returns the lines of output.  The variant
+// filter is applied to the first module named in the query.
+func (g *graph) runQuery(query string, filter variantFilter) ([]string, error) {
+	function, args, err := parseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := g.lookup(args[0], filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var to []*node
+	if len(args) > 1 {
+		to, err = g.lookup(args[1], variantFilter{})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch function {
+	case "deps":
+		return sortedNodes(reachable(from, depsOf)), nil
+	case "rdeps":
+		return sortedNodes(reachable(from, rdepsOf)), nil
+	case "somepath":
+		path := somepath(from, to)
+		if path == nil {
+			return nil, fmt.Errorf("no path from %q to %q", args[0], args[1])
+		}
+		var ret []string
+		for _, n := range path {
+			ret = append(ret, n.String())
+		}
+		return ret, nil
+	case "allpaths":
+		ret := allpaths(from, to)
+		if ret == nil {
+			return nil, fmt.Errorf("no path from %q to %q", args[0], args[1])
+		}
+		return ret, nil
+	default:
+		panic(fmt.Errorf("unhandled query function %q", function))
+	}
+}
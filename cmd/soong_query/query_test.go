@@ -0,0 +1,163 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testGraph returns a graph with shared and static arm64 variants of libfoo and libbar.  libfoo
+// also has an arm variant with no dependencies, and libasan has a single asan variant.
+//
+//	app -> libfoo (shared) -> libbar (shared) -> libc
+//	       libfoo (static) -> libbar (static) -> libc
+func testGraph() *graph {
+	g := newGraph()
+
+	app := g.addNode("app", "android_arm64_armv8-a", "arm64")
+	fooShared := g.addNode("libfoo", "android_arm64_armv8-a_shared", "arm64")
+	fooStatic := g.addNode("libfoo", "android_arm64_armv8-a_static", "arm64")
+	g.addNode("libfoo", "android_arm_armv7-a-neon_shared", "arm")
+	barShared := g.addNode("libbar", "android_arm64_armv8-a_shared", "arm64")
+	barStatic := g.addNode("libbar", "android_arm64_armv8-a_static", "arm64")
+	libc := g.addNode("libc", "android_arm64_armv8-a_shared", "arm64")
+	g.addNode("libasan", "android_arm64_armv8-a_shared_asan", "arm64")
+
+	g.addEdge(app, fooShared)
+	g.addEdge(fooShared, barShared)
+	g.addEdge(fooStatic, barStatic)
+	g.addEdge(barShared, libc)
+	g.addEdge(barStatic, libc)
+
+	return g
+}
+
+var queryTestCases = []struct {
+	query  string
+	filter variantFilter
+	out    []string
+	err    bool
+}{
+	{
+		query: "deps(libbar)",
+		out: []string{
+			"libc (android_arm64_armv8-a_shared)",
+		},
+	},
+	{
+		query:  "deps(libfoo)",
+		filter: variantFilter{link: "static"},
+		out: []string{
+			"libbar (android_arm64_armv8-a_static)",
+			"libc (android_arm64_armv8-a_shared)",
+		},
+	},
+	{
+		query:  "rdeps(libbar)",
+		filter: variantFilter{link: "static"},
+		out: []string{
+			"libfoo (android_arm64_armv8-a_static)",
+		},
+	},
+	{
+		query: "rdeps(libc)",
+		out: []string{
+			"app (android_arm64_armv8-a)",
+			"libbar (android_arm64_armv8-a_shared)",
+			"libbar (android_arm64_armv8-a_static)",
+			"libfoo (android_arm64_armv8-a_shared)",
+			"libfoo (android_arm64_armv8-a_static)",
+		},
+	},
+	{
+		query: "somepath(app, libc)",
+		out: []string{
+			"app (android_arm64_armv8-a)",
+			"libfoo (android_arm64_armv8-a_shared)",
+			"libbar (android_arm64_armv8-a_shared)",
+			"libc (android_arm64_armv8-a_shared)",
+		},
+	},
+	{
+		query: " allpaths( libfoo ,libc ) ",
+		out: []string{
+			"libbar (android_arm64_armv8-a_shared) -> libc (android_arm64_armv8-a_shared)",
+			"libbar (android_arm64_armv8-a_static) -> libc (android_arm64_armv8-a_shared)",
+			"libfoo (android_arm64_armv8-a_shared) -> libbar (android_arm64_armv8-a_shared)",
+			"libfoo (android_arm64_armv8-a_static) -> libbar (android_arm64_armv8-a_static)",
+		},
+	},
+	{
+		query:  "deps(libfoo)",
+		filter: variantFilter{arch: "arm"},
+		out:    nil,
+	},
+	{
+		query:  "deps(libasan)",
+		filter: variantFilter{asan: true},
+		out:    nil,
+	},
+
+	// Errors
+	{
+		query: "deps(libmissing)",
+		err:   true,
+	},
+	{
+		query:  "deps(libfoo)",
+		filter: variantFilter{arch: "x86"},
+		err:    true,
+	},
+	{
+		query:  "deps(libfoo)",
+		filter: variantFilter{asan: true},
+		err:    true,
+	},
+	{
+		query: "somepath(libc, app)",
+		err:   true,
+	},
+	{
+		query: "deps(libfoo, libbar)",
+		err:   true,
+	},
+	{
+		query: "why(libfoo)",
+		err:   true,
+	},
+	{
+		query: "deps libfoo",
+		err:   true,
+	},
+	{
+		query: "somepath(libfoo,)",
+		err:   true,
+	},
+}
+
+func TestQuery(t *testing.T) {
+	g := testGraph()
+	for _, test := range queryTestCases {
+		got, err := g.runQuery(test.query, test.filter)
+		if err != nil && !test.err {
+			t.Errorf("%q: unexpected error %s", test.query, err.Error())
+		} else if err == nil && test.err {
+			t.Errorf("%q: expected error, got %q", test.query, got)
+		} else if !test.err && !reflect.DeepEqual(got, test.out) {
+			t.Errorf("%q: expected %q, got %q", test.query, test.out, got)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// soong_schema writes a JSON Schema for the soong.variables product variables file, which can be
+// used to check product variables files in editors and presubmits.  The sanitizer names are the
+// ones registered by the packages that are plugins for soong_schema.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"android/soong/android"
+)
+
+var outFile = flag.String("o", "", "write the schema to this file instead of stdout")
+
+func main() {
+	flag.Parse()
+
+	if flag.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, "usage: soong_schema [-o <file>]")
+		os.Exit(2)
+	}
+
+	schema, err := android.ProductVariablesSchema()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+	schema = append(schema, '\n')
+
+	if *outFile == "" {
+		_, err = os.Stdout.Write(schema)
+	} else {
+		err = ioutil.WriteFile(*outFile, schema, 0666)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
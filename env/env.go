@@ -27,6 +27,23 @@ import (
 type envFileEntry struct{ Key, Value string }
 type envFileData []envFileEntry
 
+// envReadersFileEntry records the modules or config.Getenv call sites that read an environment
+// variable while generating the build manifest.
+type envReadersFileEntry struct {
+	Key     string
+	Readers []string
+}
+
+// EnvChange describes an environment variable whose value differs from the value recorded in the
+// environment file.
+type EnvChange struct {
+	Key, Old, New string
+}
+
+func (c EnvChange) String() string {
+	return fmt.Sprintf("%s (%q -> %q)", c.Key, c.Old, c.New)
+}
+
 func WriteEnvFile(filename string, envDeps map[string]string) error {
 	contents := make(envFileData, 0, len(envDeps))
 	for key, value := range envDeps {
@@ -50,33 +67,97 @@ func WriteEnvFile(filename string, envDeps map[string]string) error {
 	return nil
 }
 
-func StaleEnvFile(filename string) (bool, error) {
+// ReadersFile returns the name of the file that records the readers of the environment variables
+// in the environment file filename.  The build manifest does not depend on it, it is only used to
+// explain why the environment file changed.
+func ReadersFile(filename string) string {
+	return filename + ".readers"
+}
+
+// WriteEnvReadersFile writes the modules or call sites in readers that read each environment
+// variable to filename.
+func WriteEnvReadersFile(filename string, readers map[string][]string) error {
+	keys := make([]string, 0, len(readers))
+	for key := range readers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	contents := make([]envReadersFileEntry, 0, len(readers))
+	for _, key := range keys {
+		keyReaders := append([]string(nil), readers[key]...)
+		sort.Strings(keyReaders)
+		contents = append(contents, envReadersFileEntry{key, keyReaders})
+	}
+
+	data, err := json.MarshalIndent(contents, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	data = append(data, '\n')
+
+	return ioutil.WriteFile(filename, data, 0664)
+}
+
+// ReadEnvReadersFile returns the readers of each environment variable recorded in filename.
+func ReadEnvReadersFile(filename string) (map[string][]string, error) {
 	data, err := ioutil.ReadFile(filename)
 	if err != nil {
-		return true, err
+		return nil, err
+	}
+
+	var contents []envReadersFileEntry
+
+	err = json.Unmarshal(data, &contents)
+	if err != nil {
+		return nil, err
+	}
+
+	readers := make(map[string][]string, len(contents))
+	for _, entry := range contents {
+		readers[entry.Key] = entry.Readers
+	}
+
+	return readers, nil
+}
+
+// ChangedEnv returns the environment variables in filename whose value differs from the current
+// environment.
+func ChangedEnv(filename string) ([]EnvChange, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
 	}
 
 	var contents envFileData
 
 	err = json.Unmarshal(data, &contents)
 	if err != nil {
-		return true, err
+		return nil, err
 	}
 
-	var changed []string
+	var changed []EnvChange
 	for _, entry := range contents {
-		key := entry.Key
-		old := entry.Value
-		cur := os.Getenv(key)
-		if old != cur {
-			changed = append(changed, fmt.Sprintf("%s (%q -> %q)", key, old, cur))
+		cur := os.Getenv(entry.Key)
+		if entry.Value != cur {
+			changed = append(changed, EnvChange{entry.Key, entry.Value, cur})
 		}
 	}
 
+	return changed, nil
+}
+
+func StaleEnvFile(filename string) (bool, error) {
+	changed, err := ChangedEnv(filename)
+	if err != nil {
+		return true, err
+	}
+
 	if len(changed) > 0 {
 		fmt.Printf("environment variables changed value:\n")
-		for _, s := range changed {
-			fmt.Printf("   %s\n", s)
+		for _, c := range changed {
+			fmt.Printf("   %s\n", c)
 		}
 		return true, nil
 	}
@@ -0,0 +1,71 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package genrule
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"android/soong/android"
+)
+
+// AndroidMk exports the outputs of an installable genrule to Make as prebuilt ETC modules.  Soong
+// doesn't install device files in builds that are embedded in Make, so without them the outputs
+// would not be installed.  A genrule with more than one output gets one module per output, named
+// <name>_<output>, and a phony module <name> that requires all of them.
+func (g *generator) AndroidMk() (ret android.AndroidMkData, err error) {
+	if len(g.installPaths) == 0 {
+		ret.Disabled = true
+		return ret, nil
+	}
+
+	ret.Custom = func(w io.Writer, name, prefix string) error {
+		var names []string
+		for i, install := range g.installPaths {
+			moduleName := name
+			if len(g.installPaths) > 1 {
+				moduleName = name + "_" + install.Base()
+			}
+			names = append(names, moduleName)
+
+			dir, file := filepath.Split(install.RelPathString())
+			ext := filepath.Ext(file)
+
+			fmt.Fprintln(w, "\ninclude $(CLEAR_VARS)")
+			fmt.Fprintln(w, "LOCAL_PATH :=", g.moduleDir)
+			fmt.Fprintln(w, "LOCAL_MODULE :=", moduleName)
+			fmt.Fprintln(w, "LOCAL_MODULE_CLASS := ETC")
+			fmt.Fprintln(w, "LOCAL_PREBUILT_MODULE_FILE :=", g.outputFiles[i].String())
+			fmt.Fprintln(w, "LOCAL_MODULE_PATH := $(OUT_DIR)/"+filepath.Clean(dir))
+			fmt.Fprintln(w, "LOCAL_MODULE_STEM :=", strings.TrimSuffix(file, ext))
+			fmt.Fprintln(w, "LOCAL_MODULE_SUFFIX :=", ext)
+			fmt.Fprintln(w, "include $(BUILD_PREBUILT)")
+		}
+
+		if len(names) > 1 {
+			fmt.Fprintln(w, "\ninclude $(CLEAR_VARS)")
+			fmt.Fprintln(w, "LOCAL_PATH :=", g.moduleDir)
+			fmt.Fprintln(w, "LOCAL_MODULE :=", name)
+			fmt.Fprintln(w, "LOCAL_REQUIRED_MODULES :=", strings.Join(names, " "))
+			fmt.Fprintln(w, "include $(BUILD_PHONY_PACKAGE)")
+		}
+
+		return nil
+	}
+
+	return ret, nil
+}
@@ -16,6 +16,7 @@ package genrule
 
 import (
 	"fmt"
+	"path/filepath"
 	"strings"
 
 	"github.com/google/blueprint"
@@ -26,12 +27,18 @@ import (
 func init() {
 	android.RegisterModuleType("gensrcs", GenSrcsFactory)
 	android.RegisterModuleType("genrule", GenRuleFactory)
+	android.RegisterModuleType("genrule_defaults", defaultsFactory)
 }
 
 var (
 	pctx = android.NewPackageContext("android/soong/genrule")
 )
 
+func init() {
+	pctx.Import("github.com/google/blueprint/bootstrap")
+	pctx.StaticVariable("sboxCmd", filepath.Join("${bootstrap.ToolDir}", "sbox"))
+}
+
 type SourceFileGenerator interface {
 	GeneratedSourceFiles() android.Paths
 	GeneratedHeaderDirs() android.Paths
@@ -41,20 +48,37 @@ type HostToolProvider interface {
 	HostToolPath() android.OptionalPath
 }
 
+// SourceDirGenerator is implemented by generators that may write an unknown set of files into a
+// directory.  For those, GeneratedSourceFiles only returns a stamp file that is updated whenever
+// the directory is regenerated, and GeneratedFileList returns a file listing the paths of all
+// generated files relative to GeneratedSourceDir.  The file list is only updated when its contents
+// change.
+type SourceDirGenerator interface {
+	GeneratedSourceDir() android.Path
+	GeneratedFileList() android.OptionalPath
+}
+
 type generatorProperties struct {
 	// command to run on one or more input files.  Available variables for substitution:
 	// $(location): the path to the first entry in tools or tool_files
 	// $(location <label>): the path to the tool or tool_file with name <label>
+	// $(locations <label>): the paths to the tool or tool_file with name <label>, for tool_files
+	//     that expand to more than one file
 	// $(in): one or more input files
+	// $(in <name>): the output files of the module <name> referenced in srcs as ":<name>" or
+	//   ":<name>{.tag}", or the source file <name>, relative to the module directory, from srcs
+	//   (genrule only)
 	// $(out): a single output file
+	// $(out <name>): the output file <name> from out (genrule only)
 	// $(deps): a file to which dependencies will be written, if the depfile property is set to true
 	// $(genDir): the sandbox directory for this tool; contains $(out)
+	// $(module_dir): the directory containing the Android.bp file of this module
 	// $$: a literal $
 	//
 	// DO NOT directly reference paths to files in the source tree, or the
 	// command will be missing proper dependencies to re-run if the files
 	// change.
-	Cmd string
+	Cmd *string
 
 	// Enable reading a file containing dependencies in gcc format after the command completes
 	Depfile bool
@@ -64,14 +88,27 @@ type generatorProperties struct {
 	Tools []string
 
 	// Local file that is used as the tool
-	Tool_files []string
+	Tool_files []string `android:"path"`
 
 	// List of directories to export generated headers from
 	Export_include_dirs []string
+
+	// Run the command in a temporary directory that only contains the srcs, tools and
+	// tool_files, and fail if the command does not write every output or writes any other file.
+	Sandbox bool
+
+	// Install the outputs onto the device, in install_dir on the system partition, or on the
+	// vendor partition if proprietary is set.
+	Installable bool
+
+	// Directory relative to the root of the partition to install the outputs in, for example
+	// "etc/firmware".
+	Install_dir string
 }
 
 type generator struct {
 	android.ModuleBase
+	android.DefaultableModule
 
 	properties generatorProperties
 
@@ -79,10 +116,21 @@ type generator struct {
 
 	deps android.Paths
 	rule blueprint.Rule
+	// The command after the $() variables have been expanded, before ninja expands ${in} and
+	// ${out}
+	rawCommand string
 
 	exportedIncludeDirs android.Paths
 
 	outputFiles android.Paths
+
+	outputDir android.Path
+	fileList  android.OptionalPath
+
+	// The install paths of the outputs of an installable genrule, and the directory of its
+	// Android.bp file, for AndroidMk
+	installPaths []android.OutputPath
+	moduleDir    string
 }
 
 type taskFunc func(ctx android.ModuleContext) []generateTask
@@ -90,6 +138,16 @@ type taskFunc func(ctx android.ModuleContext) []generateTask
 type generateTask struct {
 	in  android.Paths
 	out android.WritablePaths
+
+	// fileList is set if the command writes an unknown set of files into $(genDir).  out then
+	// only contains a stamp file, and the list of files that were written is stored in fileList.
+	fileList android.WritablePath
+
+	// namedIn maps the names of the modules referenced in srcs, and the paths relative to the
+	// module directory of the source files in srcs, to their paths for $(in <name>).  namedOut
+	// maps each entry in out to its path for $(out <name>).
+	namedIn  map[string]android.Paths
+	namedOut map[string]android.WritablePath
 }
 
 func (g *generator) GeneratedSourceFiles() android.Paths {
@@ -100,6 +158,44 @@ func (g *generator) GeneratedHeaderDirs() android.Paths {
 	return g.exportedIncludeDirs
 }
 
+var _ android.ExportedIncludeDirsProducer = (*generator)(nil)
+
+func (g *generator) ExportedIncludeDirs() []string {
+	return g.exportedIncludeDirs.Strings()
+}
+
+var _ android.OutputFileProducer = (*generator)(nil)
+
+// OutputFiles returns the output files of the generator for module references in srcs.  A tag
+// that is a file extension, for example ":gen{.h}", selects the output files with that extension.
+func (g *generator) OutputFiles(tag string) (android.Paths, error) {
+	if g.fileList.Valid() {
+		return nil, fmt.Errorf("out_dir genrules can only be used in generated_headers of cc modules " +
+			"and generated_sources of java modules")
+	}
+	if tag == "" {
+		return g.outputFiles, nil
+	}
+	if !strings.HasPrefix(tag, ".") {
+		return nil, fmt.Errorf("unsupported tag %q, must be a file extension", tag)
+	}
+	var ret android.Paths
+	for _, outputFile := range g.outputFiles {
+		if outputFile.Ext() == tag {
+			ret = append(ret, outputFile)
+		}
+	}
+	return ret, nil
+}
+
+func (g *generator) GeneratedSourceDir() android.Path {
+	return g.outputDir
+}
+
+func (g *generator) GeneratedFileList() android.OptionalPath {
+	return g.fileList
+}
+
 func (g *generator) DepsMutator(ctx android.BottomUpMutatorContext) {
 	if g, ok := ctx.Module().(*generator); ok {
 		if len(g.properties.Tools) > 0 {
@@ -125,19 +221,22 @@ func (g *generator) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 		g.exportedIncludeDirs = append(g.exportedIncludeDirs, android.PathForModuleGen(ctx, ""))
 	}
 
-	tools := map[string]android.Path{}
+	tools := map[string]android.Paths{}
 
 	if len(g.properties.Tools) > 0 {
 		ctx.VisitDirectDeps(func(module blueprint.Module) {
+			if ctx.OtherModuleDependencyTag(module) == android.SourceDepTag {
+				return
+			}
 			if t, ok := module.(HostToolProvider); ok {
 				p := t.HostToolPath()
 				if p.Valid() {
 					g.deps = append(g.deps, p.Path())
 					tool := ctx.OtherModuleName(module)
 					if _, exists := tools[tool]; !exists {
-						tools[tool] = p.Path()
+						tools[tool] = android.Paths{p.Path()}
 					} else {
-						ctx.ModuleErrorf("multiple tools for %q, %q and %q", tool, tools[tool], p.Path().String())
+						ctx.ModuleErrorf("multiple tools for %q, %q and %q", tool, tools[tool].Strings(), p.Path().String())
 					}
 				} else {
 					ctx.ModuleErrorf("host tool %q missing output file", ctx.OtherModuleName(module))
@@ -149,26 +248,55 @@ func (g *generator) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	}
 
 	for _, tool := range g.properties.Tool_files {
-		toolPath := android.PathForModuleSrc(ctx, tool)
-		g.deps = append(g.deps, toolPath)
+		toolPaths := ctx.ExpandSources([]string{tool}, nil)
+		g.deps = append(g.deps, toolPaths...)
 		if _, exists := tools[tool]; !exists {
-			tools[tool] = toolPath
+			tools[tool] = toolPaths
 		} else {
-			ctx.ModuleErrorf("multiple tools for %q, %q and %q", tool, tools[tool], toolPath.String())
+			ctx.ModuleErrorf("multiple tools for %q, %q and %q", tool, tools[tool].Strings(), toolPaths.Strings())
+		}
+	}
+
+	tasks := g.tasks(ctx)
+	outDir := len(tasks) == 1 && tasks[0].fileList != nil
+	if outDir && g.properties.Sandbox {
+		ctx.PropertyErrorf("sandbox", "cannot be used with out_dir")
+	}
+
+	// location returns the single path of the tool with the given label
+	location := func(label string) (string, error) {
+		paths, ok := tools[label]
+		if !ok {
+			return "", fmt.Errorf("unknown location label %q", label)
+		}
+		if len(paths) != 1 {
+			return "", fmt.Errorf("label %q has %d paths, use $(locations %s)", label, len(paths), label)
 		}
+		return paths[0].String(), nil
 	}
 
-	cmd, err := android.Expand(g.properties.Cmd, func(name string) (string, error) {
+	// namedTask returns the only task, for variables that refer to the paths of a single task
+	namedTask := func(name string) (generateTask, error) {
+		if len(tasks) != 1 || tasks[0].namedIn == nil {
+			return generateTask{}, fmt.Errorf("$(%s) is only supported by genrule", name)
+		}
+		return tasks[0], nil
+	}
+
+	cmd, err := android.Expand(android.String(g.properties.Cmd), func(name string) (string, error) {
 		switch name {
 		case "location":
 			if len(g.properties.Tools) > 0 {
-				return tools[g.properties.Tools[0]].String(), nil
+				return location(g.properties.Tools[0])
 			} else {
-				return tools[g.properties.Tool_files[0]].String(), nil
+				return location(g.properties.Tool_files[0])
 			}
 		case "in":
 			return "${in}", nil
 		case "out":
+			if outDir {
+				return "", fmt.Errorf("$(out) cannot be used with out_dir, use $(genDir)")
+			}
 			return "${out}", nil
 		case "depfile":
 			if !g.properties.Depfile {
@@ -177,13 +305,44 @@ func (g *generator) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 			return "${depfile}", nil
 		case "genDir":
 			return android.PathForModuleGen(ctx, "").String(), nil
+		case "module_dir":
+			return android.PathForModuleSrc(ctx).String(), nil
 		default:
 			if strings.HasPrefix(name, "location ") {
 				label := strings.TrimSpace(strings.TrimPrefix(name, "location "))
-				if tool, ok := tools[label]; ok {
-					return tool.String(), nil
+				return location(label)
+			} else if strings.HasPrefix(name, "locations ") {
+				label := strings.TrimSpace(strings.TrimPrefix(name, "locations "))
+				if paths, ok := tools[label]; ok {
+					return strings.Join(paths.Strings(), " "), nil
+				} else {
+					return "", fmt.Errorf("unknown locations label %q", label)
+				}
+			} else if strings.HasPrefix(name, "in ") {
+				src := strings.TrimSpace(strings.TrimPrefix(name, "in "))
+				task, err := namedTask("in <name>")
+				if err != nil {
+					return "", err
+				}
+				if paths, ok := task.namedIn[src]; ok {
+					return strings.Join(paths.Strings(), " "), nil
 				} else {
-					return "", fmt.Errorf("unknown location label %q", label)
+					return "", fmt.Errorf("unknown input %q, must be a module referenced in srcs "+
+						"or a source file in srcs", src)
+				}
+			} else if strings.HasPrefix(name, "out ") {
+				out := strings.TrimSpace(strings.TrimPrefix(name, "out "))
+				task, err := namedTask("out <name>")
+				if err != nil {
+					return "", err
+				}
+				if outDir {
+					return "", fmt.Errorf("$(out <name>) cannot be used with out_dir, use $(genDir)")
+				}
+				if path, ok := task.namedOut[out]; ok {
+					return path.String(), nil
+				} else {
+					return "", fmt.Errorf("unknown output %q, must be an entry in out", out)
 				}
 			}
 			return "", fmt.Errorf("unknown variable '$(%s)'", name)
@@ -193,6 +352,7 @@ func (g *generator) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	if err != nil {
 		ctx.PropertyErrorf("cmd", "%s", err.Error())
 	}
+	g.rawCommand = cmd
 
 	ruleParams := blueprint.RuleParams{
 		Command: cmd,
@@ -202,11 +362,52 @@ func (g *generator) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 		ruleParams.Deps = blueprint.DepsGCC
 		args = append(args, "depfile")
 	}
+	if outDir {
+		// Clear out files left over from a previous run, then list the files the command wrote.
+		// The list is only replaced if it changed, so that anything that depends on it is not
+		// rebuilt every time the command runs.
+		genDir := android.PathForModuleGen(ctx, "").String()
+		ruleParams.Command = fmt.Sprintf("rm -rf %s && mkdir -p %s && (%s) && "+
+			"(cd %s && find . -type f -o -type l | sed -e 's|^\\./||' | LC_ALL=C sort) > ${fileList}.tmp && "+
+			"if cmp -s ${fileList}.tmp ${fileList}; then rm ${fileList}.tmp; else mv ${fileList}.tmp ${fileList}; fi && "+
+			"touch ${out}",
+			genDir, genDir, cmd, genDir)
+		ruleParams.Restat = true
+		args = append(args, "fileList")
+	}
+	if g.properties.Sandbox {
+		ruleParams.Command = fmt.Sprintf("${sboxCmd} -sandbox-path %s -c %s ${sboxArgs}",
+			android.PathForOutput(ctx, "sbox").String(), shellQuote(cmd))
+		ruleParams.CommandDeps = []string{"${sboxCmd}"}
+		args = append(args, "sboxArgs")
+	}
 	g.rule = ctx.Rule(pctx, "generator", ruleParams, args...)
 
-	for _, task := range g.tasks(ctx) {
+	inputs := append(android.Paths(nil), g.deps...)
+	for _, task := range tasks {
+		inputs = append(inputs, task.in...)
+	}
+	android.CheckHermeticCommand(ctx, "cmd", cmd, inputs)
+
+	for _, task := range tasks {
 		g.generateSourceFile(ctx, task)
 	}
+
+	if g.properties.Installable {
+		if outDir {
+			ctx.PropertyErrorf("installable", "cannot be used with out_dir")
+			return
+		}
+		if g.properties.Install_dir == "" {
+			ctx.PropertyErrorf("install_dir", "must be set if installable is true")
+			return
+		}
+		installDir := android.PathForModuleDeviceInstall(ctx, g.properties.Install_dir)
+		for _, outputFile := range g.outputFiles {
+			g.installPaths = append(g.installPaths, ctx.InstallFile(installDir, outputFile))
+		}
+		g.moduleDir = ctx.ModuleDir()
+	}
 }
 
 func (g *generator) generateSourceFile(ctx android.ModuleContext, task generateTask) {
@@ -217,8 +418,36 @@ func (g *generator) generateSourceFile(ctx android.ModuleContext, task generateT
 		Implicits: g.deps,
 	}
 	if g.properties.Depfile {
-		depfile := android.GenPathWithExt(ctx, "", task.out[0], task.out[0].Ext()+".d")
-		params.Depfile = depfile
+		if task.fileList != nil {
+			// Keep the depfile out of $(genDir) so that it is not part of the file list
+			params.Depfile = android.PathForModuleOut(ctx, "gen.d")
+		} else {
+			depfile := android.GenPathWithExt(ctx, "", task.out[0], task.out[0].Ext()+".d")
+			params.Depfile = depfile
+		}
+	}
+	if task.fileList != nil {
+		params.ImplicitOutput = task.fileList
+		params.Args = map[string]string{
+			"fileList": task.fileList.String(),
+		}
+		g.outputDir = android.PathForModuleGen(ctx, "")
+		g.fileList = android.OptionalPathForPath(task.fileList)
+	}
+	if g.properties.Sandbox {
+		var sboxArgs []string
+		for _, in := range append(append(android.Paths(nil), task.in...), g.deps...) {
+			sboxArgs = append(sboxArgs, "-i "+in.String())
+		}
+		for _, out := range task.out {
+			sboxArgs = append(sboxArgs, "-o "+out.String())
+		}
+		if params.Depfile != nil {
+			sboxArgs = append(sboxArgs, "-o "+params.Depfile.String())
+		}
+		params.Args = map[string]string{
+			"sboxArgs": strings.Join(sboxArgs, " "),
+		}
 	}
 	ctx.ModuleBuild(pctx, params)
 
@@ -227,6 +456,12 @@ func (g *generator) generateSourceFile(ctx android.ModuleContext, task generateT
 	}
 }
 
+// shellQuote quotes s so that it is passed to sbox as a single argument.  Ninja variables in s
+// are still expanded, as ninja substitutes them before the command is passed to the shell.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
 func generatorFactory(tasks taskFunc, props ...interface{}) (blueprint.Module, []interface{}) {
 	module := &generator{
 		tasks: tasks,
@@ -234,7 +469,9 @@ func generatorFactory(tasks taskFunc, props ...interface{}) (blueprint.Module, [
 
 	props = append(props, &module.properties)
 
-	return android.InitAndroidModule(module, props...)
+	_, props = android.InitAndroidModule(module, props...)
+
+	return android.InitDefaultableModule(module, module, props...)
 }
 
 func GenSrcsFactory() (blueprint.Module, []interface{}) {
@@ -257,7 +494,7 @@ func GenSrcsFactory() (blueprint.Module, []interface{}) {
 
 type genSrcsProperties struct {
 	// list of input files
-	Srcs []string
+	Srcs []string `android:"path"`
 
 	// extension that will be substituted for each output file
 	Output_extension string
@@ -267,14 +504,51 @@ func GenRuleFactory() (blueprint.Module, []interface{}) {
 	properties := &genRuleProperties{}
 
 	tasks := func(ctx android.ModuleContext) []generateTask {
+		var in android.Paths
+		namedIn := make(map[string]android.Paths, len(properties.Srcs))
+		moduleDir := android.PathForModuleSrc(ctx).String()
+		for _, src := range properties.Srcs {
+			paths := ctx.ExpandSources([]string{src}, nil)
+			in = append(in, paths...)
+			if module, _ := android.SrcIsModule(src); module != "" {
+				namedIn[module] = append(namedIn[module], paths...)
+				continue
+			}
+			for _, path := range paths {
+				name, err := filepath.Rel(moduleDir, path.String())
+				if err != nil {
+					name = path.String()
+				}
+				namedIn[name] = append(namedIn[name], path)
+			}
+		}
+
+		if properties.Out_dir {
+			if len(properties.Out) > 0 {
+				ctx.PropertyErrorf("out", "cannot be used with out_dir")
+			}
+			return []generateTask{
+				{
+					in:       in,
+					out:      android.WritablePaths{android.PathForModuleOut(ctx, "gen.stamp")},
+					fileList: android.PathForModuleOut(ctx, "gen.list"),
+					namedIn:  namedIn,
+				},
+			}
+		}
+
 		outs := make(android.WritablePaths, len(properties.Out))
+		namedOut := make(map[string]android.WritablePath, len(properties.Out))
 		for i, out := range properties.Out {
 			outs[i] = android.PathForModuleGen(ctx, out)
+			namedOut[out] = outs[i]
 		}
 		return []generateTask{
 			{
-				in:  ctx.ExpandSources(properties.Srcs, nil),
-				out: outs,
+				in:       in,
+				out:      outs,
+				namedIn:  namedIn,
+				namedOut: namedOut,
 			},
 		}
 	}
@@ -284,8 +558,45 @@ func GenRuleFactory() (blueprint.Module, []interface{}) {
 
 type genRuleProperties struct {
 	// list of input files
-	Srcs []string
+	Srcs []string `android:"path"`
 
 	// names of the output files that will be generated
 	Out []string
+
+	// The command writes an unknown set of files into $(genDir) instead of the files listed in
+	// out.  $(genDir) is cleared before every run.  Modules that depend on this module depend on a
+	// stamp file that is updated on every run, and on a list of the generated files.  cc modules
+	// can only use the module in generated_headers, java modules can use it in generated_sources.
+	Out_dir bool
+}
+
+//
+// Defaults
+//
+
+type Defaults struct {
+	android.ModuleBase
+	android.DefaultsModule
+}
+
+func (*Defaults) GenerateAndroidBuildActions(ctx android.ModuleContext) {
+}
+
+func (d *Defaults) DepsMutator(ctx android.BottomUpMutatorContext) {
+}
+
+func defaultsFactory() (blueprint.Module, []interface{}) {
+	return DefaultsFactory()
+}
+
+func DefaultsFactory(props ...interface{}) (blueprint.Module, []interface{}) {
+	module := &Defaults{}
+
+	props = append(props,
+		&generatorProperties{},
+		&genRuleProperties{},
+		&genSrcsProperties{},
+	)
+
+	return android.InitDefaultsModule(module, module, props...)
 }
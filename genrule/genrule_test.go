@@ -0,0 +1,374 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package genrule
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/blueprint"
+
+	"android/soong/android"
+)
+
+// testGenrule creates the source files used by the test modules in a temporary directory, and
+// changes into it because source paths are checked relative to the current directory.  It
+// returns a function that changes back to the original directory and removes the temporary one.
+func testGenrule(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "soong_genrule_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range []string{"tool.sh", "a.c", "sub/b.c", "out/.keep"} {
+		path := filepath.Join(dir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, nil, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		os.Chdir(cwd)
+		os.RemoveAll(dir)
+	}
+}
+
+func runGenrule(bp string) (*blueprint.Context, []error) {
+	return runGenruleWithConfig(bp, android.TestConfig("out"))
+}
+
+func runGenruleWithConfig(bp string, config android.Config) (*blueprint.Context, []error) {
+	ctx := android.NewContext()
+	ctx.MockFileSystem(map[string][]byte{
+		"Blueprints": []byte(bp),
+	})
+
+	_, errs := ctx.ParseBlueprintsFiles("Blueprints")
+	if len(errs) > 0 {
+		return ctx, errs
+	}
+	_, errs = ctx.PrepareBuildActions(config)
+	return ctx, errs
+}
+
+func findGenerator(ctx *blueprint.Context, name string) *generator {
+	var ret *generator
+	ctx.VisitAllModules(func(m blueprint.Module) {
+		if ctx.ModuleName(m) == name {
+			ret, _ = m.(*generator)
+		}
+	})
+	return ret
+}
+
+var genruleCmdTestCases = []struct {
+	name   string
+	cmd    string
+	expect string
+	err    string
+}{
+	{
+		name:   "in and out",
+		cmd:    "$(location) $(in) > $(out)",
+		expect: "tool.sh ${in} > ${out}",
+	},
+	{
+		name:   "locations",
+		cmd:    "$(locations :tools)",
+		expect: "out/.intermediates/tools/gen/tool1.sh out/.intermediates/tools/gen/tool2.sh",
+	},
+	{
+		name: "location with multiple paths",
+		cmd:  "$(location :tools)",
+		err:  `label ":tools" has 2 paths, use $(locations :tools)`,
+	},
+	{
+		name:   "in by file name",
+		cmd:    "$(in a.c) $(in sub/b.c)",
+		expect: "a.c sub/b.c",
+	},
+	{
+		name:   "in by module name",
+		cmd:    "$(in srcgen)",
+		expect: "out/.intermediates/srcgen/gen/srcgen.c",
+	},
+	{
+		name: "in by srcs entry",
+		cmd:  "$(in :srcgen)",
+		err:  `unknown input ":srcgen"`,
+	},
+	{
+		name:   "out by name",
+		cmd:    "$(out gen.c) $(out gen.h)",
+		expect: "out/.intermediates/gen/gen/gen.c out/.intermediates/gen/gen/gen.h",
+	},
+	{
+		name: "unknown out",
+		cmd:  "$(out gen.cpp)",
+		err:  `unknown output "gen.cpp"`,
+	},
+	{
+		name:   "module_dir and genDir",
+		cmd:    "$(module_dir) $(genDir)",
+		expect: ". out/.intermediates/gen/gen",
+	},
+}
+
+func TestGenruleCmd(t *testing.T) {
+	defer testGenrule(t)()
+
+	for _, test := range genruleCmdTestCases {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, errs := runGenrule(`
+				genrule {
+					name: "tools",
+					tool_files: ["tool.sh"],
+					cmd: "$(location) $(out)",
+					out: ["tool1.sh", "tool2.sh"],
+				}
+
+				genrule {
+					name: "srcgen",
+					tool_files: ["tool.sh"],
+					cmd: "$(location) > $(out)",
+					out: ["srcgen.c"],
+				}
+
+				genrule {
+					name: "gen",
+					tool_files: ["tool.sh", ":tools"],
+					srcs: ["a.c", "sub/b.c", ":srcgen"],
+					out: ["gen.h", "gen.c"],
+					cmd: "` + test.cmd + `",
+				}`)
+
+			if test.err != "" {
+				for _, err := range errs {
+					if strings.Contains(err.Error(), test.err) {
+						return
+					}
+				}
+				t.Fatalf("expected error containing %q, got %q", test.err, errs)
+			}
+			for _, err := range errs {
+				t.Error(err)
+			}
+			if len(errs) > 0 {
+				t.FailNow()
+			}
+
+			gen := findGenerator(ctx, "gen")
+			if gen == nil {
+				t.Fatalf("failed to find module gen")
+			}
+			if gen.rawCommand != test.expect {
+				t.Errorf("expected %q, got %q", test.expect, gen.rawCommand)
+			}
+		})
+	}
+}
+
+var genruleDefaultsTestCases = []struct {
+	name   string
+	cmd    string
+	expect string
+}{
+	{
+		name:   "cmd from defaults",
+		expect: "tool.sh a.c sub/b.c > ${out}",
+	},
+	{
+		name:   "cmd replaces defaults",
+		cmd:    `cmd: "cat $(in sub/b.c) > $(out)",`,
+		expect: "cat sub/b.c > ${out}",
+	},
+}
+
+func TestGenruleDefaults(t *testing.T) {
+	defer testGenrule(t)()
+
+	for _, test := range genruleDefaultsTestCases {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, errs := runGenrule(`
+				genrule_defaults {
+					name: "gen_defaults",
+					tool_files: ["tool.sh"],
+					srcs: ["a.c"],
+					cmd: "$(location) $(in a.c) $(in sub/b.c) > $(out)",
+				}
+
+				genrule {
+					name: "gen",
+					defaults: ["gen_defaults"],
+					srcs: ["sub/b.c"],
+					out: ["gen.c"],
+					` + test.cmd + `
+				}`)
+
+			for _, err := range errs {
+				t.Error(err)
+			}
+			if len(errs) > 0 {
+				t.FailNow()
+			}
+
+			gen := findGenerator(ctx, "gen")
+			if gen == nil {
+				t.Fatalf("failed to find module gen")
+			}
+			if gen.rawCommand != test.expect {
+				t.Errorf("expected %q, got %q", test.expect, gen.rawCommand)
+			}
+		})
+	}
+}
+
+var genruleAndroidMkTestCases = []struct {
+	name     string
+	disabled bool
+	expected string
+}{
+	{
+		name:     "gen",
+		disabled: true,
+	},
+	{
+		name: "fw",
+		expected: `
+include $(CLEAR_VARS)
+LOCAL_PATH := .
+LOCAL_MODULE := fw
+LOCAL_MODULE_CLASS := ETC
+LOCAL_PREBUILT_MODULE_FILE := out/.intermediates/fw/gen/fw.bin
+LOCAL_MODULE_PATH := $(OUT_DIR)/target/product/generic/system/etc/firmware
+LOCAL_MODULE_STEM := fw
+LOCAL_MODULE_SUFFIX := .bin
+include $(BUILD_PREBUILT)
+`,
+	},
+	{
+		name: "vendor_fw",
+		expected: `
+include $(CLEAR_VARS)
+LOCAL_PATH := .
+LOCAL_MODULE := vendor_fw_a.bin
+LOCAL_MODULE_CLASS := ETC
+LOCAL_PREBUILT_MODULE_FILE := out/.intermediates/vendor_fw/gen/a.bin
+LOCAL_MODULE_PATH := $(OUT_DIR)/target/product/generic/vendor/etc/firmware
+LOCAL_MODULE_STEM := a
+LOCAL_MODULE_SUFFIX := .bin
+include $(BUILD_PREBUILT)
+
+include $(CLEAR_VARS)
+LOCAL_PATH := .
+LOCAL_MODULE := vendor_fw_b.bin
+LOCAL_MODULE_CLASS := ETC
+LOCAL_PREBUILT_MODULE_FILE := out/.intermediates/vendor_fw/gen/b.bin
+LOCAL_MODULE_PATH := $(OUT_DIR)/target/product/generic/vendor/etc/firmware
+LOCAL_MODULE_STEM := b
+LOCAL_MODULE_SUFFIX := .bin
+include $(BUILD_PREBUILT)
+
+include $(CLEAR_VARS)
+LOCAL_PATH := .
+LOCAL_MODULE := vendor_fw
+LOCAL_REQUIRED_MODULES := vendor_fw_a.bin vendor_fw_b.bin
+include $(BUILD_PHONY_PACKAGE)
+`,
+	},
+}
+
+func TestGenruleAndroidMk(t *testing.T) {
+	defer testGenrule(t)()
+
+	deviceName := "generic"
+	config := android.TestConfig("out")
+	config.ProductVariables.DeviceName = &deviceName
+
+	ctx, errs := runGenruleWithConfig(`
+		genrule {
+			name: "gen",
+			tool_files: ["tool.sh"],
+			cmd: "$(location) $(out)",
+			out: ["gen.c"],
+		}
+
+		genrule {
+			name: "fw",
+			tool_files: ["tool.sh"],
+			cmd: "$(location) $(out)",
+			out: ["fw.bin"],
+			installable: true,
+			install_dir: "etc/firmware",
+		}
+
+		genrule {
+			name: "vendor_fw",
+			tool_files: ["tool.sh"],
+			cmd: "$(location) $(out)",
+			out: ["a.bin", "b.bin"],
+			installable: true,
+			install_dir: "etc/firmware",
+			proprietary: true,
+		}`, config)
+
+	for _, err := range errs {
+		t.Error(err)
+	}
+	if len(errs) > 0 {
+		t.FailNow()
+	}
+
+	for _, test := range genruleAndroidMkTestCases {
+		gen := findGenerator(ctx, test.name)
+		if gen == nil {
+			t.Fatalf("failed to find module %s", test.name)
+		}
+
+		data, err := gen.AndroidMk()
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if data.Disabled != test.disabled {
+			t.Errorf("%s: expected disabled %v, got %v", test.name, test.disabled, data.Disabled)
+		}
+		if test.disabled {
+			continue
+		}
+
+		buf := &bytes.Buffer{}
+		if err := data.Custom(buf, test.name, "TARGET_"); err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if buf.String() != test.expected {
+			t.Errorf("%s: expected\n%s\ngot\n%s", test.name, test.expected, buf.String())
+		}
+	}
+}
@@ -32,7 +32,7 @@ import (
 type androidAppProperties struct {
 	// path to a certificate, or the name of a certificate in the default
 	// certificate directory, or blank to use the default product certificate
-	Certificate string
+	Certificate *string
 
 	// paths to extra certificates to sign the apk with
 	Additional_certificates []string
@@ -69,7 +69,7 @@ func (a *AndroidApp) JavaDependencies(ctx AndroidJavaModuleContext) []string {
 	deps := a.javaBase.JavaDependencies(ctx)
 
 	if !a.properties.No_standard_libraries {
-		switch a.properties.Sdk_version { // TODO: Res_sdk_version?
+		switch android.String(a.properties.Sdk_version) { // TODO: Res_sdk_version?
 		case "current", "system_current", "":
 			deps = append(deps, "framework-res")
 		default:
@@ -137,7 +137,7 @@ func (a *AndroidApp) GenerateJavaBuildActions(ctx android.ModuleContext) {
 			"--product "+ctx.AConfig().ProductAaptCharacteristics())
 	}
 
-	certificate := a.appProperties.Certificate
+	certificate := android.String(a.appProperties.Certificate)
 	if certificate == "" {
 		certificate = ctx.AConfig().DefaultAppCertificate(ctx).String()
 	} else if dir, _ := filepath.Split(certificate); dir == "" {
@@ -247,7 +247,7 @@ func (a *AndroidApp) aaptFlags(ctx android.ModuleContext) ([]string, android.Pat
 		}
 	})
 
-	sdkVersion := a.properties.Sdk_version
+	sdkVersion := android.String(a.properties.Sdk_version)
 	if sdkVersion == "" {
 		sdkVersion = ctx.AConfig().PlatformSdkVersion()
 	}
@@ -54,6 +54,15 @@ var (
 			CommandDeps: []string{"$mergeLogtagsCmd"},
 			Description: "merge logtags $out",
 		})
+
+	genDirJavaList = pctx.AndroidStaticRule("genDirJavaList",
+		blueprint.RuleParams{
+			Command: "(grep -E '\\.java$$' $in || true) | sed -e 's|^|$dir/|' > $out.tmp && " +
+				"if cmp -s $out.tmp $out; then rm $out.tmp; else mv $out.tmp $out; fi",
+			Description: "genDirJavaList $out",
+			Restat:      true,
+		},
+		"dir")
 )
 
 func genAidl(ctx android.ModuleContext, aidlFile android.Path, aidlFlags string) android.Path {
@@ -85,6 +94,25 @@ func genLogtags(ctx android.ModuleContext, logtagsFile android.Path) android.Pat
 	return javaFile
 }
 
+// genDirJavaList returns a file list for javac containing every .java file listed in fileList,
+// which is the list of files that the genrule module name wrote into dir.
+func genDirJavaList(ctx android.ModuleContext, name string, dir android.Path,
+	fileList android.Path) android.Path {
+
+	javaList := android.PathForModuleGen(ctx, "gen_dir", name+".list")
+
+	ctx.ModuleBuild(pctx, android.ModuleBuildParams{
+		Rule:   genDirJavaList,
+		Output: javaList,
+		Input:  fileList,
+		Args: map[string]string{
+			"dir": dir.String(),
+		},
+	})
+
+	return javaList
+}
+
 func (j *javaBase) genSources(ctx android.ModuleContext, srcFiles android.Paths,
 	flags javaBuilderFlags) android.Paths {
 
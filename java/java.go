@@ -37,6 +37,8 @@ func init() {
 	android.RegisterModuleType("prebuilt_java_library", JavaPrebuiltFactory)
 	android.RegisterModuleType("prebuilt_sdk", SdkPrebuiltFactory)
 	android.RegisterModuleType("android_app", AndroidAppFactory)
+	android.RegisterModuleType("java_defaults", defaultsFactory)
+	android.RegisterModuleType("java_prebuilt_defaults", prebuiltDefaultsFactory)
 
 	android.RegisterSingletonType("logtags", LogtagsSingleton)
 }
@@ -57,12 +59,13 @@ func init() {
 
 type javaBaseProperties struct {
 	// list of source files used to compile the Java module.  May be .java, .logtags, .proto,
-	// or .aidl files.
-	Srcs []string `android:"arch_variant"`
+	// or .aidl files, or .jar files whose contents will be included in the resulting jar.
+	// Use ":module_name" or ":module_name{.tag}" to use the output files of another module.
+	Srcs []string `android:"arch_variant,path"`
 
 	// list of source files that should not be used to build the Java module.
 	// This is most useful in the arch/multilib variants to remove non-common files
-	Exclude_srcs []string `android:"arch_variant"`
+	Exclude_srcs []string `android:"arch_variant,path"`
 
 	// list of directories containing Java resources
 	Java_resource_dirs []string `android:"arch_variant"`
@@ -89,11 +92,16 @@ type javaBaseProperties struct {
 	// list of java libraries that will be compiled into the resulting jar
 	Java_static_libs []string `android:"arch_variant"`
 
+	// list of genrule or gensrcs modules whose outputs are compiled into the resulting jar.
+	// The outputs may be .java, .logtags or .aidl files, or .jar files whose contents will be
+	// included in the resulting jar.
+	Generated_sources []string `android:"arch_variant"`
+
 	// manifest file to be included in resulting jar
 	Manifest *string
 
 	// if not blank, set to the version of the sdk to compile against
-	Sdk_version string
+	Sdk_version *string
 
 	// Set for device java libraries, and for host versions of device java libraries
 	// built for testing
@@ -114,6 +122,7 @@ type javaBaseProperties struct {
 // the blueprint.Module interface.
 type javaBase struct {
 	android.ModuleBase
+	android.DefaultableModule
 	module JavaModuleType
 
 	properties javaBaseProperties
@@ -163,21 +172,24 @@ func NewJavaBase(base *javaBase, module JavaModuleType, hod android.HostOrDevice
 
 	props = append(props, &base.properties)
 
-	return android.InitAndroidArchModule(base, hod, android.MultilibCommon, props...)
+	_, props = android.InitAndroidArchModule(base, hod, android.MultilibCommon, props...)
+
+	return android.InitDefaultableModule(base, base, props...)
 }
 
 func (j *javaBase) BootClasspath(ctx android.BaseContext) string {
 	if ctx.Device() {
-		if j.properties.Sdk_version == "" {
+		sdkVersion := android.String(j.properties.Sdk_version)
+		if sdkVersion == "" {
 			return "core-libart"
-		} else if j.properties.Sdk_version == "current" {
+		} else if sdkVersion == "current" {
 			// TODO: !TARGET_BUILD_APPS
 			// TODO: export preprocessed framework.aidl from android_stubs_current
 			return "android_stubs_current"
-		} else if j.properties.Sdk_version == "system_current" {
+		} else if sdkVersion == "system_current" {
 			return "android_system_stubs_current"
 		} else {
-			return "sdk_v" + j.properties.Sdk_version
+			return "sdk_v" + sdkVersion
 		}
 	} else {
 		if j.properties.Dex {
@@ -204,12 +216,13 @@ func (j *javaBase) JavaDependencies(ctx AndroidJavaModuleContext) []string {
 		if bootClasspath != "" {
 			deps = append(deps, bootClasspath)
 		}
-		if ctx.Device() && j.properties.Sdk_version == "" {
+		if ctx.Device() && android.String(j.properties.Sdk_version) == "" {
 			deps = append(deps, defaultJavaLibraries...)
 		}
 	}
 	deps = append(deps, j.properties.Java_libs...)
 	deps = append(deps, j.properties.Java_static_libs...)
+	deps = append(deps, j.properties.Generated_sources...)
 
 	return deps
 }
@@ -239,6 +252,10 @@ func (j *javaBase) collectDeps(ctx android.ModuleContext) (classpath android.Pat
 	aidlIncludeDirs android.Paths, srcFileLists android.Paths) {
 
 	ctx.VisitDirectDeps(func(module blueprint.Module) {
+		if ctx.OtherModuleDependencyTag(module) == android.SourceDepTag {
+			return
+		}
+
 		otherName := ctx.OtherModuleName(module)
 		if javaDep, ok := module.(JavaDependency); ok {
 			if otherName == j.BootClasspath(ctx) {
@@ -303,7 +320,42 @@ func (j *javaBase) GenerateJavaBuildActions(ctx android.ModuleContext) {
 		flags.aidlFlags = "$aidlFlags"
 	}
 
+	srcFiles := ctx.ExpandSources(j.properties.Srcs, j.properties.Exclude_srcs)
+
 	var javacDeps android.Paths
+	var genSrcFileLists android.Paths
+
+	ctx.VisitDirectDeps(func(module blueprint.Module) {
+		if ctx.OtherModuleDependencyTag(module) == android.SourceDepTag {
+			// Already expanded into srcFiles by ExpandSources
+			return
+		}
+		if gen, ok := module.(genrule.SourceFileGenerator); ok {
+			if dirGen, ok := module.(genrule.SourceDirGenerator); ok && dirGen.GeneratedFileList().Valid() {
+				// The generated files are not known until the genrule has run, pass them to
+				// javac in a file list and depend on the stamp file of the genrule.
+				genSrcFileLists = append(genSrcFileLists, genDirJavaList(ctx, ctx.OtherModuleName(module),
+					dirGen.GeneratedSourceDir(), dirGen.GeneratedFileList().Path()))
+				javacDeps = append(javacDeps, gen.GeneratedSourceFiles()...)
+				return
+			}
+			srcFiles = append(srcFiles, gen.GeneratedSourceFiles()...)
+		}
+	})
+
+	// .jar files are put on the classpath and their contents are included in the resulting jar
+	var jars android.Paths
+	var nonJarSrcFiles android.Paths
+	for _, srcFile := range srcFiles {
+		if srcFile.Ext() == ".jar" {
+			jars = append(jars, srcFile)
+		} else {
+			nonJarSrcFiles = append(nonJarSrcFiles, srcFile)
+		}
+	}
+	srcFiles = nonJarSrcFiles
+
+	classpath = append(classpath, jars...)
 
 	if bootClasspath.Valid() {
 		flags.bootClasspath = "-bootclasspath " + bootClasspath.String()
@@ -315,19 +367,14 @@ func (j *javaBase) GenerateJavaBuildActions(ctx android.ModuleContext) {
 		javacDeps = append(javacDeps, classpath...)
 	}
 
-	srcFiles := ctx.ExpandSources(j.properties.Srcs, j.properties.Exclude_srcs)
-
 	srcFiles = j.genSources(ctx, srcFiles, flags)
 
-	ctx.VisitDirectDeps(func(module blueprint.Module) {
-		if gen, ok := module.(genrule.SourceFileGenerator); ok {
-			srcFiles = append(srcFiles, gen.GeneratedSourceFiles()...)
-		}
-	})
-
 	srcFileLists = append(srcFileLists, j.ExtraSrcLists...)
+	srcFileLists = append(srcFileLists, genSrcFileLists...)
+
+	hasSrcs := len(srcFiles) > 0 || len(genSrcFileLists) > 0
 
-	if len(srcFiles) > 0 {
+	if hasSrcs {
 		// Compile java sources into .class files
 		classes := TransformJavaToClasses(ctx, srcFiles, srcFileLists, flags, javacDeps)
 		if ctx.Failed() {
@@ -337,6 +384,12 @@ func (j *javaBase) GenerateJavaBuildActions(ctx android.ModuleContext) {
 		classJarSpecs = append([]jarSpec{classes}, classJarSpecs...)
 	}
 
+	for i, jar := range jars {
+		classes, resources := TransformPrebuiltJarToClasses(ctx, fmt.Sprintf("extracted_srcs/%d", i), jar)
+		classJarSpecs = append(classJarSpecs, classes)
+		resourceJarSpecs = append(resourceJarSpecs, resources)
+	}
+
 	resourceJarSpecs = append(ResourceDirsToJarSpecs(ctx, j.properties.Java_resource_dirs, j.properties.Exclude_java_resource_dirs),
 		resourceJarSpecs...)
 
@@ -359,7 +412,7 @@ func (j *javaBase) GenerateJavaBuildActions(ctx android.ModuleContext) {
 			return
 		}
 
-		classes, _ := TransformPrebuiltJarToClasses(ctx, outputFile)
+		classes, _ := TransformPrebuiltJarToClasses(ctx, "extracted", outputFile)
 		classJarSpecs = []jarSpec{classes}
 	}
 
@@ -367,7 +420,7 @@ func (j *javaBase) GenerateJavaBuildActions(ctx android.ModuleContext) {
 	j.classJarSpecs = classJarSpecs
 	j.classpathFile = outputFile
 
-	if j.properties.Dex && len(srcFiles) > 0 {
+	if j.properties.Dex && hasSrcs {
 		dxFlags := j.properties.Dxflags
 		if false /* emma enabled */ {
 			// If you instrument class files that have local variable debug information in
@@ -379,11 +432,11 @@ func (j *javaBase) GenerateJavaBuildActions(ctx android.ModuleContext) {
 			dxFlags = append(dxFlags, "--no-locals")
 		}
 
-		if ctx.AConfig().Getenv("NO_OPTIMIZE_DX") != "" {
+		if ctx.Getenv("NO_OPTIMIZE_DX") != "" {
 			dxFlags = append(dxFlags, "--no-optimize")
 		}
 
-		if ctx.AConfig().Getenv("GENERATE_DEX_DEBUG") != "" {
+		if ctx.Getenv("GENERATE_DEX_DEBUG") != "" {
 			dxFlags = append(dxFlags,
 				"--debug",
 				"--verbose",
@@ -424,6 +477,26 @@ func (j *javaBase) AidlIncludeDirs() android.Paths {
 	return j.exportAidlIncludeDirs
 }
 
+var _ android.ExportedIncludeDirsProducer = (*javaBase)(nil)
+
+func (j *javaBase) ExportedIncludeDirs() []string {
+	return j.exportAidlIncludeDirs.Strings()
+}
+
+var _ android.OutputFileProducer = (*javaBase)(nil)
+
+// OutputFiles returns the jar containing the classes and resources of the module, for modules
+// that include it in their srcs.
+func (j *javaBase) OutputFiles(tag string) (android.Paths, error) {
+	if tag != "" && tag != ".jar" {
+		return nil, fmt.Errorf("unsupported tag %q", tag)
+	}
+	if j.classpathFile == nil {
+		return nil, fmt.Errorf("module has no output file")
+	}
+	return android.Paths{j.classpathFile}, nil
+}
+
 var _ logtagsProducer = (*javaBase)(nil)
 
 func (j *javaBase) logtags() android.Paths {
@@ -506,6 +579,7 @@ type javaPrebuiltProperties struct {
 
 type JavaPrebuilt struct {
 	android.ModuleBase
+	android.DefaultableModule
 
 	properties javaPrebuiltProperties
 
@@ -523,7 +597,7 @@ func (j *JavaPrebuilt) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	}
 	prebuilt := android.PathForModuleSrc(ctx, j.properties.Srcs[0])
 
-	classJarSpec, resourceJarSpec := TransformPrebuiltJarToClasses(ctx, prebuilt)
+	classJarSpec, resourceJarSpec := TransformPrebuiltJarToClasses(ctx, "extracted", prebuilt)
 
 	j.classpathFile = prebuilt
 	j.classJarSpecs = []jarSpec{classJarSpec}
@@ -537,6 +611,13 @@ func (j *JavaPrebuilt) ClasspathFile() android.Path {
 	return j.classpathFile
 }
 
+func (j *JavaPrebuilt) OutputFiles(tag string) (android.Paths, error) {
+	if tag != "" && tag != ".jar" {
+		return nil, fmt.Errorf("unsupported tag %q", tag)
+	}
+	return android.Paths{j.classpathFile}, nil
+}
+
 func (j *JavaPrebuilt) ClassJarSpecs() []jarSpec {
 	return j.classJarSpecs
 }
@@ -552,8 +633,10 @@ func (j *JavaPrebuilt) AidlIncludeDirs() android.Paths {
 func JavaPrebuiltFactory() (blueprint.Module, []interface{}) {
 	module := &JavaPrebuilt{}
 
-	return android.InitAndroidArchModule(module, android.HostAndDeviceSupported,
+	_, props := android.InitAndroidArchModule(module, android.HostAndDeviceSupported,
 		android.MultilibCommon, &module.properties)
+
+	return android.InitDefaultableModule(module, module, props...)
 }
 
 //
@@ -592,8 +675,50 @@ func (j *sdkPrebuilt) AidlPreprocessed() android.OptionalPath {
 func SdkPrebuiltFactory() (blueprint.Module, []interface{}) {
 	module := &sdkPrebuilt{}
 
-	return android.InitAndroidArchModule(module, android.HostAndDeviceSupported,
+	_, props := android.InitAndroidArchModule(module, android.HostAndDeviceSupported,
 		android.MultilibCommon, &module.properties, &module.sdkProperties)
+
+	return android.InitDefaultableModule(module, module, props...)
+}
+
+//
+// Defaults
+//
+
+type Defaults struct {
+	android.ModuleBase
+	android.DefaultsModule
+}
+
+func (*Defaults) GenerateAndroidBuildActions(ctx android.ModuleContext) {
+}
+
+func (d *Defaults) DepsMutator(ctx android.BottomUpMutatorContext) {
+}
+
+func defaultsFactory() (blueprint.Module, []interface{}) {
+	return DefaultsFactory()
+}
+
+func DefaultsFactory(props ...interface{}) (blueprint.Module, []interface{}) {
+	module := &Defaults{}
+
+	props = append(props,
+		&javaBaseProperties{},
+		&androidAppProperties{},
+	)
+
+	return android.InitDefaultsModule(module, module, props...)
+}
+
+// prebuiltDefaultsFactory returns a defaults module for the properties of java prebuilts.  They
+// are not in java_defaults, where srcs would also set the srcs of java libraries.
+func prebuiltDefaultsFactory() (blueprint.Module, []interface{}) {
+	module := &Defaults{}
+
+	return android.InitDefaultsModule(module, module,
+		&javaPrebuiltProperties{},
+		&sdkPrebuiltProperties{})
 }
 
 func inList(s string, l []string) bool {
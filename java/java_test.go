@@ -0,0 +1,111 @@
+// Copyright 2017 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/google/blueprint"
+
+	"android/soong/android"
+)
+
+func findModule(ctx *blueprint.Context, name string) blueprint.Module {
+	var ret blueprint.Module
+	ctx.VisitAllModules(func(m blueprint.Module) {
+		if ctx.ModuleName(m) == name {
+			ret = m
+		}
+	})
+	return ret
+}
+
+func TestJavaDefaults(t *testing.T) {
+	buildDir, err := ioutil.TempDir("", "soong_java_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(buildDir)
+
+	ctx := android.NewContext()
+	ctx.MockFileSystem(map[string][]byte{
+		"Blueprints": []byte(`
+			java_defaults {
+				name: "java_defaults",
+				sdk_version: "current",
+				javacflags: ["-Xlint:all"],
+				aidl_includes: ["aidl"],
+				no_standard_libraries: true,
+			}
+
+			java_prebuilt_defaults {
+				name: "java_prebuilt_defaults",
+				srcs: ["prebuilt.jar"],
+			}
+
+			java_library_host {
+				name: "foo",
+				defaults: ["java_defaults"],
+				javacflags: ["-Werror"],
+			}
+
+			java_library_host {
+				name: "bar",
+				defaults: ["java_defaults"],
+				sdk_version: "system_current",
+			}
+
+			prebuilt_java_library {
+				name: "baz",
+				defaults: ["java_prebuilt_defaults"],
+			}
+		`),
+	})
+
+	_, errs := ctx.ParseBlueprintsFiles("Blueprints")
+	if len(errs) == 0 {
+		_, errs = ctx.PrepareBuildActions(android.TestConfig(buildDir))
+	}
+	for _, err := range errs {
+		t.Error(err)
+	}
+	if len(errs) > 0 {
+		t.FailNow()
+	}
+
+	foo := findModule(ctx, "foo").(*JavaLibrary)
+	if expected := []string{"-Xlint:all", "-Werror"}; !reflect.DeepEqual(foo.properties.Javacflags, expected) {
+		t.Errorf("foo: expected javacflags %q, got %q", expected, foo.properties.Javacflags)
+	}
+	if expected := []string{"aidl"}; !reflect.DeepEqual(foo.properties.Aidl_includes, expected) {
+		t.Errorf("foo: expected aidl_includes %q, got %q", expected, foo.properties.Aidl_includes)
+	}
+	if sdkVersion := android.String(foo.properties.Sdk_version); sdkVersion != "current" {
+		t.Errorf("foo: expected sdk_version %q, got %q", "current", sdkVersion)
+	}
+
+	bar := findModule(ctx, "bar").(*JavaLibrary)
+	if sdkVersion := android.String(bar.properties.Sdk_version); sdkVersion != "system_current" {
+		t.Errorf("bar: expected sdk_version %q, got %q", "system_current", sdkVersion)
+	}
+
+	baz := findModule(ctx, "baz").(*JavaPrebuilt)
+	if expected := []string{"prebuilt.jar"}; !reflect.DeepEqual(baz.properties.Srcs, expected) {
+		t.Errorf("baz: expected srcs %q, got %q", expected, baz.properties.Srcs)
+	}
+}